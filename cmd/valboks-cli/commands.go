@@ -1,77 +1,310 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
+	"io/fs"
+	"net"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"time"
+	"valboks/internal/config"
+	"valboks/internal/output"
 	"valboks/pkg/dropbox"
+	"valboks/pkg/storage"
 )
 
+// timeFormat is used for every timestamp the commands print
+const timeFormat = "2006-01-02 15:04:05"
+
 func newAuthCommand() *cobra.Command {
 	var appKey, appSecret string
+	var noBrowser bool
+	var port int
 
 	cmd := &cobra.Command{
 		Use:   "auth",
-		Short: "Authenticat with Dropbox",
-		Long:  "TBA",
+		Short: "Authenticate with Dropbox",
+		Long: `Authorize valboks-cli to access your Dropbox account.
+
+The authorization page is opened in your browser and Dropbox redirects back
+to a server listening on localhost. The redirect URI
+http://localhost:<port>/callback must be registered in your app's settings.
+
+With --no-browser the link is only printed and the code Dropbox shows after
+authorizing is read from the terminal, which works on headless machines.
+
+The app secret is optional, the PKCE flow does not need it.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			printVerbose(cmd, "Starting authentication process")
 
-			if appKey == "" || appSecret == "" {
-				return fmt.Errorf("Starting authentication process")
+			printer := getPrinter(cmd)
+			// Instructions must not end up in machine readable output
+			messages := os.Stdout
+			if !printer.IsText() {
+				messages = os.Stderr
 			}
 
-			fmt.Println("📋 Dropbox Authentication")
-			fmt.Println("=========================")
-			fmt.Printf("App Key: %s\n", appKey)
-			fmt.Println()
-			fmt.Println("To complete authentication:")
-			fmt.Println("1. Visit https://www.dropbox.com/developers/apps")
-			fmt.Println("2. Find your app and go to the 'Settings' tab")
-			fmt.Println("3. Generate an access token")
-			fmt.Println("4. Enter the access token below")
-			fmt.Println()
+			ctx, cancel := context.WithTimeout(cmd.Context(), 5*time.Minute)
+			defer cancel()
 
-			fmt.Print("Enter your access token: ")
-			var accessToken string
-			_, err := fmt.Scanln(&accessToken)
+			var listener net.Listener
+			redirectURL := ""
+			if !noBrowser {
+				var err error
+				listener, err = net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+				if err != nil {
+					return fmt.Errorf("failed to listen for the authorization redirect on port %d: %w", port, err)
+				}
+				defer listener.Close()
+				redirectURL = fmt.Sprintf("http://localhost:%d%s", port, dropbox.CallbackPath)
+			}
+
+			authorizer, err := dropbox.NewAuthorizer(appKey, appSecret, redirectURL)
 			if err != nil {
-				return fmt.Errorf("error reading access token: %w", err)
+				return err
+			}
+			authURL := authorizer.AuthURL()
+
+			fmt.Fprintln(messages, "📋 Dropbox Authentication")
+			fmt.Fprintln(messages, "=========================")
+
+			var code string
+			if noBrowser {
+				fmt.Fprintln(messages, "1. Visit the following URL and allow access:")
+				fmt.Fprintln(messages, authURL)
+				fmt.Fprintln(messages, "2. Copy the authorization code Dropbox shows")
+				fmt.Fprintln(messages)
+				fmt.Fprint(messages, "Enter the authorization code: ")
+
+				_, err = fmt.Scanln(&code)
+				if err != nil {
+					return fmt.Errorf("error reading authorization code: %w", err)
+				}
+				code = strings.TrimSpace(code)
+				if code == "" {
+					return fmt.Errorf("authorization code cannot be empty")
+				}
+			} else {
+				fmt.Fprintln(messages, "Opening your browser to authorize access. If it does not open, visit:")
+				fmt.Fprintln(messages, authURL)
+
+				err = openBrowser(authURL)
+				if err != nil {
+					printVerbose(cmd, "Could not open browser: %v", err)
+				}
+
+				printVerbose(cmd, "Waiting for the redirect to %s", redirectURL)
+				code, err = authorizer.WaitForCode(ctx, listener)
+				if err != nil {
+					return err
+				}
 			}
 
-			fmt.Printf("Access token: %s\n", accessToken)
+			printVerbose(cmd, "Exchanging authorization code for tokens")
 
-			accessToken = strings.TrimSpace(accessToken)
-			if accessToken == "" {
-				return fmt.Errorf("access token cannot be empty")
+			token, err := authorizer.Exchange(ctx, code)
+			if err != nil {
+				return err
 			}
 
-			printVerbose(cmd, "Testing connection with provided token")
+			printVerbose(cmd, "Testing connection with the new access token")
 
-			client := dropbox.NewClient(accessToken)
-			err = client.TestConnection()
+			client := dropbox.NewClient(dropbox.StaticToken(token.AccessToken))
+			err = client.TestConnection(cmd.Context())
 			if err != nil {
-				return fmt.Errorf("authentication to dropbox failed - invalid token: %w", err)
+				return fmt.Errorf("authentication to dropbox failed: %w", err)
 			}
 
 			//Saving the creds
-			configManager.SetCredentials(appKey, appSecret, accessToken)
+			configManager.SetCredentials(appKey, appSecret, token.AccessToken)
+			configManager.SetTokens(token.AccessToken, token.RefreshToken)
 			err = configManager.Save()
 			if err != nil {
 				return fmt.Errorf("error saving configuration: %w", err)
 			}
-
-			fmt.Println("✅ Authentication successful!")
 			printVerbose(cmd, "Configuration saved successfully")
+
+			if !printer.IsText() {
+				return printer.PrintRecord(output.Record{
+					{Name: "status", Value: "authenticated"},
+					{Name: "profile", Value: configManager.Profile()},
+					{Name: "account_id", Value: optional(token.AccountID)},
+					{Name: "refresh_token", Value: token.RefreshToken != ""},
+				})
+			}
+
+			fmt.Printf("✅ Authentication successful! (profile: %s)\n", configManager.Profile())
+			if token.RefreshToken == "" {
+				fmt.Println("⚠️  Dropbox did not issue a refresh token, you will need to run 'auth' again when the access token expires")
+			}
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&appKey, "app-key", "", "Dropbox API app key (required)")
-	cmd.Flags().StringVar(&appSecret, "app-secret", "", "Dropbox API app secret (required)")
+	cmd.Flags().StringVar(&appSecret, "app-secret", "", "Dropbox API app secret")
+	cmd.Flags().BoolVar(&noBrowser, "no-browser", false, "Print the authorization URL and read the code from the terminal")
+	cmd.Flags().IntVar(&port, "port", 53682, "Local port for the authorization redirect")
 	cmd.MarkFlagRequired("app-key")
-	cmd.MarkFlagRequired("app-secret")
+
+	return cmd
+}
+
+func newLogoutCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logout",
+		Short: "Revoke the access token and remove stored credentials",
+		Long: `Revoke the access token of the current profile on Dropbox and remove the
+stored tokens and app secret from the config file.
+
+The stored credentials are removed even if Dropbox could not be reached, in
+that case the command fails and the token may still be valid until it expires.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !configManager.IsConfigured() {
+				return errNotAuthenticated
+			}
+
+			profile := configManager.Profile()
+			printVerbose(cmd, "Revoking access token of profile %s", profile)
+
+			revokeErr := newClient(cmd).RevokeToken(cmd.Context())
+
+			configManager.ClearCredentials()
+			err := configManager.Save()
+			if err != nil {
+				return fmt.Errorf("error saving configuration: %w", err)
+			}
+
+			if revokeErr != nil {
+				return fmt.Errorf("removed stored credentials of profile '%s', but the token could not be revoked on Dropbox: %w", profile, revokeErr)
+			}
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(output.Record{
+					{Name: "profile", Value: profile},
+					{Name: "revoked", Value: true},
+				})
+			}
+
+			fmt.Printf("✅ Logged out of profile '%s', the token was revoked on Dropbox\n", profile)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newAccountCommand() *cobra.Command {
+	var checkFree uint64
+
+	cmd := &cobra.Command{
+		Use:     "account",
+		Aliases: []string{"whoami"},
+		Short:   "Show the current account and its space usage",
+		Long: `Show the Dropbox account of the current profile and how much space it uses.
+
+With --check-free only the free space is checked and the command fails if
+fewer than the given number of bytes are available, which is useful in
+scripts before large uploads.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !configManager.IsConfigured() {
+				return errNotAuthenticated
+			}
+
+			client := newClient(cmd)
+			printer := getPrinter(cmd)
+
+			printVerbose(cmd, "Getting space usage")
+			usage, err := client.GetSpaceUsage(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			if cmd.Flags().Changed("check-free") {
+				if usage.Allocated == 0 {
+					return fmt.Errorf("dropbox did not report a space limit for this account")
+				}
+				if usage.Free() < checkFree {
+					return fmt.Errorf("only %s free, %s required", formatSize(usage.Free()), formatSize(checkFree))
+				}
+
+				if !printer.IsText() {
+					return printer.PrintRecord(output.Record{
+						{Name: "free", Value: usage.Free()},
+						{Name: "required", Value: checkFree},
+					})
+				}
+				fmt.Printf("✅ %s free, %s required\n", formatSize(usage.Free()), formatSize(checkFree))
+				return nil
+			}
+
+			printVerbose(cmd, "Getting current account")
+			account, err := client.GetAccount(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			if !printer.IsText() {
+				return printer.PrintRecord(output.Record{
+					{Name: "account_id", Value: account.AccountID},
+					{Name: "name", Value: account.Name},
+					{Name: "email", Value: account.Email},
+					{Name: "email_verified", Value: account.EmailVerified},
+					{Name: "account_type", Value: account.AccountType},
+					{Name: "country", Value: optional(account.Country)},
+					{Name: "locale", Value: optional(account.Locale)},
+					{Name: "team_id", Value: optional(account.TeamID)},
+					{Name: "team_name", Value: optional(account.TeamName)},
+					{Name: "team_member_id", Value: optional(account.TeamMemberID)},
+					{Name: "root_namespace_id", Value: account.RootNamespaceID},
+					{Name: "home_namespace_id", Value: account.HomeNamespaceID},
+					{Name: "space_used", Value: usage.Used},
+					{Name: "space_allocated", Value: usage.Allocated},
+					{Name: "space_free", Value: usage.Free()},
+					{Name: "team_space", Value: usage.Team},
+					{Name: "team_space_used", Value: usage.TeamUsed},
+				})
+			}
+
+			verified := ""
+			if !account.EmailVerified {
+				verified = " (not verified)"
+			}
+
+			fmt.Printf("👤 %s\n", account.Name)
+			fmt.Printf("	Email: %s%s\n", account.Email, verified)
+			fmt.Printf("	Account ID: %s\n", account.AccountID)
+			fmt.Printf("	Type: %s\n", account.AccountType)
+			if account.TeamID != "" {
+				fmt.Printf("	Team: %s (%s)\n", account.TeamName, account.TeamID)
+				fmt.Printf("	Team member ID: %s\n", account.TeamMemberID)
+			}
+			fmt.Printf("	Root namespace: %s\n", account.RootNamespaceID)
+			if account.HomeNamespaceID != account.RootNamespaceID {
+				fmt.Printf("	Home namespace: %s\n", account.HomeNamespaceID)
+			}
+
+			fmt.Println("💾 Space")
+			fmt.Printf("	Used: %s\n", formatSize(usage.Used))
+			if usage.Team {
+				fmt.Printf("	Used by team: %s\n", formatSize(usage.TeamUsed))
+			}
+			if usage.Allocated > 0 {
+				fmt.Printf("	Allocated: %s\n", formatSize(usage.Allocated))
+				fmt.Printf("	Free: %s\n", formatSize(usage.Free()))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().Uint64Var(&checkFree, "check-free", 0, "Fail unless at least this many bytes are free")
 
 	return cmd
 }
@@ -84,48 +317,55 @@ func newListCommand() *cobra.Command {
 		Use:     "ls [path]",
 		Aliases: []string{"list"},
 		Short:   "List files and folders",
-		Long:    `List files and folders in the specified Dropbox path.`,
-		Args:    cobra.MaximumNArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			if !configManager.IsConfigured() {
-				return fmt.Errorf("not authenticated - run 'auth' command first")
-			}
+		Long: `List files and folders in the specified Dropbox path or local directory.
 
-			path := "/"
+` + locationHelp,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			arg := "/"
 			if len(args) > 0 {
-				path = args[0]
+				arg = args[0]
 			}
 
-			printVerbose(cmd, "Listing contents of: %s", path)
-
-			client := dropbox.NewClient(configManager.GetConfig().AccessToken)
-			fileInfos, err := client.ListFolder(path)
+			backend, path, err := newBackends(cmd).open(arg)
 			if err != nil {
 				return err
 			}
 
-			if len(fileInfos) == 0 {
-				fmt.Println("📂 Empty folder")
-				return nil
-			}
+			printVerbose(cmd, "Listing contents of: %s", arg)
 
-			printVerbose(cmd, "Found %d items", len(fileInfos))
+			// Machine readable output is collected, text is printed as entries arrive
+			printer := getPrinter(cmd)
+			var records []output.Record
+			count := 0
 
-			for _, info := range fileInfos {
-				if longFormat {
-					if info.IsFolder {
-						fmt.Printf("📁 %-30s <DIR>\n", info.Name)
-					} else {
-						fmt.Printf("📁 %-30s %d bytes\n", info.Name, info.Size)
-					}
+			for info, err := range backend.List(cmd.Context(), path) {
+				if err != nil {
+					return err
+				}
+				count++
+
+				if !printer.IsText() {
+					records = append(records, storageInfoRecord(&info))
+				} else if longFormat {
+					printLongEntry(&info)
+				} else if info.IsDir {
+					fmt.Printf("📁 %s\n", info.Name)
 				} else {
-					if info.IsFolder {
-						fmt.Printf("📁 %s\n", info.Name)
-					} else {
-						fmt.Printf("📁 %s\n", info.Name)
-					}
+					fmt.Printf("📄 %s\n", info.Name)
 				}
 			}
+
+			if !printer.IsText() {
+				return printer.PrintList(records)
+			}
+
+			if count == 0 {
+				fmt.Println("📂 Empty folder")
+				return nil
+			}
+
+			printVerbose(cmd, "Found %d items", count)
 			return nil
 		},
 	}
@@ -135,128 +375,1282 @@ func newListCommand() *cobra.Command {
 	return cmd
 }
 
-func newUploadCommand() *cobra.Command {
-	var overwrite bool
+func newFindCommand() *cobra.Command {
+	var name, kind string
 
 	cmd := &cobra.Command{
-		Use:     "put [local_path] [dropbox_path]",
-		Aliases: []string{"upload"},
-		Short:   "Upload a file to Dropbox",
-		Long:    `Upload a file from your local filesystem to Dropbox.`,
-		Args:    cobra.ExactArgs(2),
+		Use:   "find [path]",
+		Short: "Find files and folders below a path",
+		Long: `Print the path of every file and folder below the specified path, including
+the path itself. Results are printed as they are found.
+
+` + locationHelp,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if !configManager.IsConfigured() {
-				return fmt.Errorf("not authenticated - run 'auth' command first")
+			arg := "/"
+			if len(args) > 0 {
+				arg = args[0]
 			}
 
-			localPath := args[0]
-			dropboxPath := args[1]
+			if _, err := path.Match(name, ""); err != nil {
+				return fmt.Errorf("invalid --name pattern '%s': %w", name, err)
+			}
+			if kind != "" && kind != "f" && kind != "d" {
+				return fmt.Errorf("--type must be f or d")
+			}
 
-			//Check if local file exists
-			if _, err := os.Stat(localPath); os.IsNotExist(err) {
-				return fmt.Errorf("local file '%s' does not exist", localPath)
+			backend, root, err := newBackends(cmd).open(arg)
+			if err != nil {
+				return err
 			}
 
-			printVerbose(cmd, "Uploading %s to %s (overwrite: %v)", localPath, dropboxPath, overwrite)
+			printVerbose(cmd, "Searching: %s", arg)
+
+			printer := getPrinter(cmd)
+			var records []output.Record
+
+			err = storage.Walk(cmd.Context(), backend, root, func(entryPath string, info *storage.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if kind == "f" && info.IsDir || kind == "d" && !info.IsDir {
+					return nil
+				}
+				if matched, _ := path.Match(name, path.Base(entryPath)); !matched {
+					return nil
+				}
 
-			client := dropbox.NewClient(configManager.GetConfig().AccessToken)
-			err := client.UploadFile(localPath, dropboxPath, overwrite)
+				if !printer.IsText() {
+					records = append(records, storageInfoRecord(info))
+				} else {
+					fmt.Println(entryPath)
+				}
+				return nil
+			})
 			if err != nil {
-				_ = fmt.Errorf("failed to up load the file to dropbox, err: %v", err)
 				return err
 			}
 
-			fmt.Printf("✅ Uploaded '%s' to '%s'\n", localPath, dropboxPath)
+			if !printer.IsText() {
+				return printer.PrintList(records)
+			}
 			return nil
 		},
 	}
 
-	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite existing files")
+	cmd.Flags().StringVar(&name, "name", "*", "Only print entries whose name matches this shell pattern")
+	cmd.Flags().StringVar(&kind, "type", "", "Only print files (f) or folders (d)")
 
 	return cmd
 }
 
-func newDeleteCommand() *cobra.Command {
-	var force bool
-
+func newDiskUsageCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "rm [path]",
-		Aliases: []string{"delete"},
-		Short:   "Delete a file or folder",
-		Long:    `Delete a file or folder from Dropbox.`,
-		Args:    cobra.ExactArgs(1),
+		Use:   "du [path]",
+		Short: "Show the total size of a folder",
+		Long: `Add up the size of every file below the specified path.
+
+` + locationHelp,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if !configManager.IsConfigured() {
-				return fmt.Errorf("not authenticated - run 'auth' command first")
+			arg := "/"
+			if len(args) > 0 {
+				arg = args[0]
 			}
 
-			path := args[0]
+			backend, root, err := newBackends(cmd).open(arg)
+			if err != nil {
+				return err
+			}
 
-			if !force {
-				fmt.Printf("Are you sure you want to delete '%s'?, (Y/N): ", path)
-				var response string
+			printVerbose(cmd, "Measuring: %s", arg)
 
-				fmt.Scanln(&response)
-				if strings.ToLower(response) != "Y" && strings.ToLower(response) != "yes" {
-					fmt.Println("Deletion cancelled")
-					return nil
+			var size uint64
+			var fileCount, folderCount int
+			err = storage.Walk(cmd.Context(), backend, root, func(_ string, info *storage.FileInfo, err error) error {
+				if err != nil {
+					return err
 				}
+				if info.IsDir {
+					folderCount++
+				} else {
+					fileCount++
+					size += uint64(info.Size)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
 			}
 
-			printVerbose(cmd, "Deleting: %s", path)
-
-			client := dropbox.NewClient(configManager.GetConfig().AccessToken)
-			err := client.DeletePath(path)
-			if err != nil {
-				_ = fmt.Errorf("failed to delete the file")
-				return nil
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(output.Record{
+					{Name: "path", Value: arg},
+					{Name: "size", Value: size},
+					{Name: "files", Value: fileCount},
+					{Name: "folders", Value: folderCount},
+				})
 			}
 
-			fmt.Printf("✅ Deleted '%s'\n", path)
+			fmt.Printf("📋 %s in %d files and %d folders: %s\n", formatSize(size), fileCount, folderCount, arg)
 			return nil
 		},
 	}
 
-	cmd.Flags().BoolVarP(&force, "force", "f", false, "Force deletion without confirmation")
-
 	return cmd
 }
 
-func newInfoCommand() *cobra.Command {
+// printLongEntry prints an entry in the long listing format of ls
+func printLongEntry(info *storage.FileInfo) {
+	if info.IsDir {
+		fmt.Printf("📁 %-30s %14s\n", info.Name, "<DIR>")
+		return
+	}
+
+	modified, rev := info.ModTime, ""
+	if dropboxInfo, ok := info.Sys.(*dropbox.FileInfo); ok {
+		modified, rev = dropboxInfo.ServerModified, dropboxInfo.Rev
+	}
+	fmt.Printf("📄 %-30s %14d  %s  %s\n", info.Name, info.Size, modified.Local().Format(timeFormat), rev)
+}
+
+func newDownloadCommand() *cobra.Command {
+	var recursive bool
+	var concurrency, rangeSizeMB int
+
 	cmd := &cobra.Command{
-		Use:   "info [path]",
-		Short: "Get information about a file or folder",
-		Long:  `Get detailed information about a file or folder in Dropbox,`,
-		Args:  cobra.ExactArgs(1),
+		Use:     "get [dropbox_path] [local_path]",
+		Aliases: []string{"download"},
+		Short:   "Download a file or folder from Dropbox",
+		Long: `Download a file from Dropbox to your local filesystem.
+
+Files are written to a temporary .part file and only moved into place once
+their size and content hash have been verified. Re-running an interrupted
+download continues from the partial file.
+
+Files larger than --range-size can be fetched as several byte ranges in
+parallel with --concurrency. Parallel downloads cannot be resumed.
+
+With -r a whole folder is mirrored to disk. Local files that already match
+the remote size and content hash are skipped.
+
+The paths can also be given as dropbox:/path and file:///path URIs, paths
+without a scheme are Dropbox paths for the source and local paths for the
+destination. Between any other two locations get copies like cp does.`,
+		Args: cobra.RangeArgs(1, 2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if !configManager.IsConfigured() {
-				return fmt.Errorf("not authenticated - run 'auth' command first")
+			b := newBackends(cmd)
+			src, dropboxPath, err := b.open(args[0])
+			if err != nil {
+				return err
 			}
 
-			path := args[0]
+			var dst storage.Storage
+			var localPath string
+			if len(args) > 1 {
+				dst, localPath, err = b.openIn(args[1], storage.SchemeFile)
+				if err != nil {
+					return err
+				}
+			}
 
-			printVerbose(cmd, "Getting info for: %s", path)
+			if src != b.dropbox || (dst != nil && dst != b.local) {
+				if dst == nil {
+					dst, localPath = storage.NewLocal(), path.Base(dropboxPath)
+				}
+				return copyBetween(cmd, args[0], src, dropboxPath, dst, localPath, recursive,
+					"downloads from Dropbox to local files", "concurrency", "range-size")
+			}
+
+			if concurrency < 1 || rangeSizeMB < 1 {
+				return fmt.Errorf("concurrency and range size must be at least 1")
+			}
+
+			opts := dropbox.DownloadOptions{
+				Concurrency: concurrency,
+				RangeSize:   int64(rangeSizeMB) * 1024 * 1024,
+			}
 
-			client := dropbox.NewClient(configManager.GetConfig().AccessToken)
-			info, err := client.GetFileInfo(path)
+			client := b.client
+			info, err := client.GetFileInfo(cmd.Context(), dropboxPath)
 			if err != nil {
-				fmt.Errorf("failed to get the file Info: %+v", err)
 				return err
 			}
 
-			fmt.Printf("📋 Information for '%s'\n", path)
-			fmt.Printf("	Name: %s\n", info.Name)
-			fmt.Printf("	Path: %s\n", info.Path)
+			if localPath == "" {
+				localPath = info.Name
+			}
+			localPath = filepath.FromSlash(localPath)
+
 			if info.IsFolder {
-				fmt.Printf("	Type: Folder\n")
-			} else {
-				fmt.Printf("	Type: File\n")
-				fmt.Printf("	Size: %d bytes\n", info.Size)
+				if !recursive {
+					return fmt.Errorf("'%s' is a folder - use -r to download it", dropboxPath)
+				}
+
+				printVerbose(cmd, "Downloading folder %s to %s", dropboxPath, localPath)
+
+				var downloaded, skipped int
+				err = client.DownloadFolder(cmd.Context(), dropboxPath, localPath, opts, func(path string, wasSkipped bool) {
+					if wasSkipped {
+						skipped++
+						printVerbose(cmd, "Skipped %s (unchanged)", path)
+					} else {
+						downloaded++
+						printVerbose(cmd, "Downloaded %s", path)
+					}
+				})
+				if err != nil {
+					return err
+				}
+
+				if printer := getPrinter(cmd); !printer.IsText() {
+					return printer.PrintRecord(folderTransferRecord(dropboxPath, localPath, downloaded, skipped, 0))
+				}
+
+				fmt.Printf("✅ Downloaded '%s' to '%s' (%d downloaded, %d unchanged)\n", dropboxPath, localPath, downloaded, skipped)
+				return nil
+			}
+
+			if stat, err := os.Stat(localPath); err == nil && stat.IsDir() {
+				localPath = filepath.Join(localPath, info.Name)
+			}
+
+			unchanged, err := dropbox.IsLocalCopy(localPath, info)
+			if err != nil {
+				return err
+			}
+			if unchanged {
+				if printer := getPrinter(cmd); !printer.IsText() {
+					return printer.PrintRecord(transferRecord(dropboxPath, localPath, "unchanged"))
+				}
+				fmt.Printf("✅ '%s' is already up to date\n", localPath)
+				return nil
+			}
+
+			printVerbose(cmd, "Downloading %s to %s (concurrency: %d, range size: %d MB)", dropboxPath, localPath, concurrency, rangeSizeMB)
+
+			err = client.DownloadFile(cmd.Context(), dropboxPath, localPath, opts)
+			if err != nil {
+				return err
+			}
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(transferRecord(dropboxPath, localPath, "downloaded"))
 			}
 
+			fmt.Printf("✅ Downloaded '%s' to '%s'\n", dropboxPath, localPath)
 			return nil
 		},
 	}
 
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Download folders and their contents recursively")
+	cmd.Flags().IntVarP(&concurrency, "concurrency", "j", 1, "Number of byte ranges of a large file to download in parallel")
+	cmd.Flags().IntVar(&rangeSizeMB, "range-size", dropbox.DefaultRangeSize/(1024*1024), "Size in MB of each range of a parallel download")
+
 	return cmd
 }
+
+func newUploadCommand() *cobra.Command {
+	var overwrite, autorename, mute, resume, recursive bool
+	var ifRev string
+	var chunkSizeMB, workers int
+
+	cmd := &cobra.Command{
+		Use:     "put [local_path] [dropbox_path]",
+		Aliases: []string{"upload"},
+		Short:   "Upload a file or directory to Dropbox",
+		Long: `Upload a file from your local filesystem to Dropbox.
+
+Large files are uploaded in chunks and their progress is saved, so re-running
+an interrupted upload with the same paths continues where it stopped.
+
+With -r a whole directory tree is uploaded by several concurrent workers.
+Files already on Dropbox with the same size and content hash are skipped.
+
+The paths can also be given as file:///path and dropbox:/path URIs, paths
+without a scheme are local paths for the source and Dropbox paths for the
+destination. Between any other two locations put copies like cp does.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			b := newBackends(cmd)
+			src, localPath, err := b.openIn(args[0], storage.SchemeFile)
+			if err != nil {
+				return err
+			}
+			dst, dropboxPath, err := b.open(args[1])
+			if err != nil {
+				return err
+			}
+
+			if src != b.local || dst != b.dropbox {
+				return copyBetween(cmd, args[0], src, localPath, dst, dropboxPath, recursive,
+					"uploads from local files to Dropbox",
+					"overwrite", "if-rev", "autorename", "mute", "resume", "chunk-size", "workers")
+			}
+			localPath = filepath.FromSlash(localPath)
+
+			//Check if local file exists
+			stat, err := os.Stat(localPath)
+			if os.IsNotExist(err) {
+				return fmt.Errorf("local file '%s' does not exist: %w", localPath, err)
+			}
+			if err != nil {
+				return err
+			}
+			if stat.IsDir() && !recursive {
+				return fmt.Errorf("'%s' is a directory - use -r to upload it", localPath)
+			}
+			if recursive && (resume || ifRev != "") {
+				return fmt.Errorf("--resume and --if-rev cannot be used with -r")
+			}
+
+			if chunkSizeMB <= 0 || chunkSizeMB*1024*1024 > dropbox.MaxChunkSize {
+				return fmt.Errorf("chunk size must be between 1 and %d MB", dropbox.MaxChunkSize/(1024*1024))
+			}
+
+			opts := dropbox.UploadOptions{
+				Mode:       dropbox.WriteModeAdd,
+				Autorename: autorename,
+				Mute:       mute,
+			}
+			switch {
+			case overwrite && ifRev != "":
+				return fmt.Errorf("--overwrite and --if-rev cannot be used together")
+			case overwrite:
+				opts.Mode = dropbox.WriteModeOverwrite
+			case ifRev != "":
+				opts.Mode = dropbox.WriteModeUpdate
+				opts.Rev = ifRev
+			}
+
+			printVerbose(cmd, "Uploading %s to %s (mode: %s, chunk size: %d MB)", localPath, dropboxPath, opts.Mode, chunkSizeMB)
+
+			client := newClient(cmd,
+				dropbox.WithChunkSize(int64(chunkSizeMB)*1024*1024),
+				dropbox.WithSessionStore(newSessionStore()))
+
+			if recursive {
+				folderOpts := dropbox.FolderUploadOptions{UploadOptions: opts, Workers: workers}
+				return uploadFolder(cmd, client, localPath, dropboxPath, folderOpts)
+			}
+
+			session, err := client.PendingUpload(localPath, dropboxPath)
+			if err != nil {
+				return err
+			}
+			if session != nil {
+				printVerbose(cmd, "Resuming upload session %s at offset %d of %d", session.SessionID, session.Offset, session.Size)
+			} else if resume {
+				return fmt.Errorf("no interrupted upload of '%s' to '%s' to resume", localPath, dropboxPath)
+			}
+
+			err = client.UploadFile(cmd.Context(), localPath, dropboxPath, opts)
+			if errors.Is(err, dropbox.ErrConflict) {
+				return fmt.Errorf("%w (use --overwrite, --if-rev or --autorename to replace it)", err)
+			}
+			if err != nil {
+				return err
+			}
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(transferRecord(localPath, dropboxPath, "uploaded"))
+			}
+
+			fmt.Printf("✅ Uploaded '%s' to '%s'\n", localPath, dropboxPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Upload a directory and its contents recursively")
+	cmd.Flags().IntVar(&workers, "workers", dropbox.DefaultUploadWorkers, "Number of files to upload at the same time with -r")
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite existing files")
+	cmd.Flags().StringVar(&ifRev, "if-rev", "", "Only overwrite the existing file if it is at this revision")
+	cmd.Flags().BoolVar(&autorename, "autorename", false, "Rename the upload instead of failing on a conflict")
+	cmd.Flags().BoolVar(&mute, "mute", false, "Don't notify the user's devices about the change")
+	cmd.Flags().BoolVar(&resume, "resume", false, "Only continue an interrupted upload, fail if there is none")
+	cmd.Flags().IntVar(&chunkSizeMB, "chunk-size", dropbox.DefaultChunkSize/(1024*1024), "Chunk size in MB for files uploaded through an upload session")
+
+	return cmd
+}
+
+// copyBetween copies from in src to to in dst for a get or put that is not
+// between Dropbox and the local filesystem in the direction the command is
+// built for. The flags in dropboxOnly only apply to that direction, described
+// by direction, and are rejected.
+func copyBetween(cmd *cobra.Command, source string, src storage.Storage, from string,
+	dst storage.Storage, to string, recursive bool, direction string, dropboxOnly ...string) error {
+	for _, name := range dropboxOnly {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s only applies when %s %s", name, cmd.Name(), direction)
+		}
+	}
+
+	info, err := src.Stat(cmd.Context(), from)
+	if err != nil {
+		return err
+	}
+	if info.IsDir && !recursive {
+		return fmt.Errorf("'%s' is a folder - use -r to copy it", source)
+	}
+
+	printVerbose(cmd, "Copying %s to %s", from, to)
+
+	err = storage.Copy(cmd.Context(), src, from, dst, to)
+	if err != nil {
+		return err
+	}
+
+	if printer := getPrinter(cmd); !printer.IsText() {
+		return printer.PrintRecord(transferRecord(from, to, "copied"))
+	}
+
+	fmt.Printf("✅ Copied '%s' to '%s'\n", from, to)
+	return nil
+}
+
+// uploadFolder uploads a local directory tree and prints a summary of the outcome
+func uploadFolder(cmd *cobra.Command, client *dropbox.Client, localDir, dropboxPath string, opts dropbox.FolderUploadOptions) error {
+	if opts.Workers < 1 {
+		return fmt.Errorf("workers must be at least 1")
+	}
+
+	printVerbose(cmd, "Uploading directory %s to %s (mode: %s, workers: %d)", localDir, dropboxPath, opts.Mode, opts.Workers)
+
+	printer := getPrinter(cmd)
+
+	summary, err := client.UploadFolder(cmd.Context(), localDir, dropboxPath, opts, func(localPath string, status dropbox.UploadStatus, err error) {
+		switch status {
+		case dropbox.UploadStatusUploaded:
+			printVerbose(cmd, "Uploaded %s", localPath)
+		case dropbox.UploadStatusSkipped:
+			printVerbose(cmd, "Skipped %s", localPath)
+		case dropbox.UploadStatusFailed:
+			if printer.IsText() {
+				fmt.Printf("❌ %s: %v\n", localPath, err)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", localPath, err)
+			}
+		}
+	})
+	if summary == nil {
+		return err
+	}
+
+	// Print what was done even if the upload stopped early
+	if !printer.IsText() {
+		printErr := printer.PrintRecord(folderTransferRecord(localDir, dropboxPath, summary.Uploaded, summary.Skipped, len(summary.Failed)))
+		if printErr != nil {
+			return printErr
+		}
+	} else {
+		fmt.Printf("📋 Uploaded: %d, skipped: %d, failed: %d\n", summary.Uploaded, summary.Skipped, len(summary.Failed))
+	}
+	if err != nil {
+		return err
+	}
+	if len(summary.Failed) > 0 {
+		return fmt.Errorf("%d files could not be uploaded", len(summary.Failed))
+	}
+
+	if printer.IsText() {
+		fmt.Printf("✅ Uploaded '%s' to '%s'\n", localDir, dropboxPath)
+	}
+	return nil
+}
+
+// errDeletionCancelled is returned by rm when the user does not confirm the deletion
+var errDeletionCancelled = errors.New("deletion cancelled")
+
+func newDeleteCommand() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:     "rm [path]",
+		Aliases: []string{"delete"},
+		Short:   "Delete a file or folder",
+		Long: `Delete a file or folder from Dropbox or the local filesystem. Unless -f is
+given the deletion has to be confirmed, declining it fails with exit code 1.
+
+` + locationHelp,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+
+			backend, backendPath, err := newBackends(cmd).open(path)
+			if err != nil {
+				return err
+			}
+
+			if !force {
+				// The prompt goes to stderr to keep stdout parseable with --output
+				fmt.Fprintf(os.Stderr, "Are you sure you want to delete '%s'? (y/N): ", path)
+				var response string
+
+				fmt.Scanln(&response)
+				response = strings.ToLower(response)
+				if response != "y" && response != "yes" {
+					return errDeletionCancelled
+				}
+			}
+
+			printVerbose(cmd, "Deleting: %s", path)
+
+			err = backend.Delete(cmd.Context(), backendPath)
+			if err != nil {
+				return err
+			}
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(output.Record{
+					{Name: "path", Value: path},
+					{Name: "status", Value: "deleted"},
+				})
+			}
+
+			fmt.Printf("✅ Deleted '%s'\n", path)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Force deletion without confirmation")
+
+	return cmd
+}
+
+func newMkdirCommand() *cobra.Command {
+	var parents bool
+
+	cmd := &cobra.Command{
+		Use:   "mkdir [path...]",
+		Short: "Create folders",
+		Long: `Create one or more folders. With -p missing parent folders are created and
+folders that already exist are left alone, otherwise the parent folder must
+exist and nothing may exist at the path yet.
+
+Several Dropbox folders are created together in a single batch.
+
+` + locationHelp,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			b := newBackends(cmd)
+
+			stores := make([]storage.Storage, len(args))
+			paths := make([]string, len(args))
+			for i, arg := range args {
+				var err error
+				stores[i], paths[i], err = b.open(arg)
+				if err != nil {
+					return err
+				}
+			}
+
+			if !parents {
+				err := checkParents(ctx, args, stores, paths)
+				if err != nil {
+					return err
+				}
+			}
+
+			errs := make([]error, len(args))
+			var batch []int
+			for i := range args {
+				if stores[i] == b.dropbox {
+					batch = append(batch, i)
+				} else {
+					errs[i] = stores[i].Mkdir(ctx, paths[i])
+				}
+			}
+
+			if len(batch) == 1 {
+				errs[batch[0]] = b.client.CreateFolder(ctx, paths[batch[0]])
+			} else if len(batch) > 1 {
+				printVerbose(cmd, "Creating %d Dropbox folders in a batch", len(batch))
+
+				batchPaths := make([]string, len(batch))
+				for j, i := range batch {
+					batchPaths[j] = paths[i]
+				}
+				batchErrs, err := b.client.CreateFolderBatch(ctx, batchPaths)
+				if err != nil {
+					return err
+				}
+				for j, i := range batch {
+					errs[i] = batchErrs[j]
+				}
+			}
+
+			printer := getPrinter(cmd)
+			var records []output.Record
+			var failed []error
+			for i, arg := range args {
+				status := "created"
+				err := errs[i]
+				if parents && errors.Is(err, fs.ErrExist) {
+					if info, statErr := stores[i].Stat(ctx, paths[i]); statErr == nil && info.IsDir {
+						status, err = "exists", nil
+					}
+				}
+
+				if err != nil {
+					failed = append(failed, err)
+					if len(args) > 1 {
+						if printer.IsText() {
+							fmt.Printf("❌ %s: %v\n", arg, err)
+						} else {
+							fmt.Fprintf(os.Stderr, "%s: %v\n", arg, err)
+						}
+					}
+					continue
+				}
+
+				if !printer.IsText() {
+					records = append(records, output.Record{
+						{Name: "path", Value: arg},
+						{Name: "status", Value: status},
+					})
+				} else if status == "exists" {
+					printVerbose(cmd, "'%s' already exists", arg)
+				} else {
+					fmt.Printf("✅ Created folder '%s'\n", arg)
+				}
+			}
+
+			if len(args) == 1 && len(failed) == 1 {
+				return failed[0]
+			}
+			if !printer.IsText() {
+				err := printer.PrintList(records)
+				if err != nil {
+					return err
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("%d folders could not be created", len(failed))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&parents, "parents", "p", false, "Create missing parent folders and accept existing folders")
+
+	return cmd
+}
+
+// checkParents fails unless the parent of every path exists or is created
+// by the same command
+func checkParents(ctx context.Context, args []string, stores []storage.Storage, paths []string) error {
+	for i, arg := range args {
+		parent := path.Dir(paths[i])
+		if parent == "/" || parent == "." {
+			continue
+		}
+
+		created := false
+		for j := range args {
+			if stores[j] == stores[i] && samePath(stores[i], path.Clean(paths[j]), parent) {
+				created = true
+				break
+			}
+		}
+		if created {
+			continue
+		}
+
+		info, err := stores[i].Stat(ctx, parent)
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("parent folder of '%s' does not exist - use -p to create it: %w", arg, err)
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir {
+			return fmt.Errorf("parent of '%s' is not a folder", arg)
+		}
+	}
+
+	return nil
+}
+
+// samePath reports whether a and b name the same entry of store. Dropbox
+// paths are case insensitive, local paths are compared exactly.
+func samePath(store storage.Storage, a, b string) bool {
+	if _, ok := store.(*storage.Dropbox); ok {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+func newCopyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "cp [source] [destination]",
+		Aliases: []string{"copy"},
+		Short:   "Copy a file or folder",
+		Long: `Copy a file or folder within Dropbox, within the local filesystem or
+between them. Missing parent folders of the destination are created, and
+nothing may exist at the destination yet.
+
+` + locationHelp,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source, destination := args[0], args[1]
+
+			printVerbose(cmd, "Copying %s to %s", source, destination)
+
+			err := runTransfer(cmd, source, destination, storage.Copy)
+			if err != nil {
+				return err
+			}
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(transferRecord(source, destination, "copied"))
+			}
+
+			fmt.Printf("✅ Copied '%s' to '%s'\n", source, destination)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newMoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "mv [source] [destination]",
+		Aliases: []string{"move"},
+		Short:   "Move a file or folder",
+		Long: `Move a file or folder within Dropbox, within the local filesystem or
+between them. Missing parent folders of the destination are created, and
+nothing may exist at the destination yet. Between Dropbox and the local
+filesystem the source is deleted once it has been copied.
+
+` + locationHelp,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source, destination := args[0], args[1]
+
+			printVerbose(cmd, "Moving %s to %s", source, destination)
+
+			err := runTransfer(cmd, source, destination, storage.Move)
+			if err != nil {
+				return err
+			}
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(transferRecord(source, destination, "moved"))
+			}
+
+			fmt.Printf("✅ Moved '%s' to '%s'\n", source, destination)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// runTransfer runs transfer between the backends of source and destination
+func runTransfer(cmd *cobra.Command, source, destination string,
+	transfer func(ctx context.Context, src storage.Storage, from string, dst storage.Storage, to string) error) error {
+	b := newBackends(cmd)
+	src, from, err := b.open(source)
+	if err != nil {
+		return err
+	}
+	dst, to, err := b.open(destination)
+	if err != nil {
+		return err
+	}
+
+	return transfer(cmd.Context(), src, from, dst, to)
+}
+
+func newInfoCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "info [path]",
+		Short: "Get information about a file or folder",
+		Long: `Get detailed information about a file or folder in Dropbox or the local
+filesystem.
+
+` + locationHelp,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+
+			backend, backendPath, err := newBackends(cmd).open(path)
+			if err != nil {
+				return err
+			}
+
+			printVerbose(cmd, "Getting info for: %s", path)
+
+			stat, err := backend.Stat(cmd.Context(), backendPath)
+			if err != nil {
+				return fmt.Errorf("failed to get the file Info: %w", err)
+			}
+
+			printer := getPrinter(cmd)
+			if !printer.IsText() {
+				return printer.PrintRecord(storageInfoRecord(stat))
+			}
+
+			fmt.Printf("📋 Information for '%s'\n", path)
+
+			info, ok := stat.Sys.(*dropbox.FileInfo)
+			if !ok {
+				fmt.Printf("	Name: %s\n", stat.Name)
+				fmt.Printf("	Path: %s\n", stat.Path)
+				if stat.IsDir {
+					fmt.Printf("	Type: Directory\n")
+				} else {
+					fmt.Printf("	Type: File\n")
+					fmt.Printf("	Size: %d bytes\n", stat.Size)
+				}
+				fmt.Printf("	Modified: %s\n", stat.ModTime.Local().Format(timeFormat))
+				return nil
+			}
+
+			fmt.Printf("	Name: %s\n", info.Name)
+			fmt.Printf("	Path: %s\n", info.PathDisplay)
+			fmt.Printf("	ID: %s\n", info.ID)
+			if info.IsFolder {
+				fmt.Printf("	Type: Folder\n")
+			} else {
+				fmt.Printf("	Type: File\n")
+				fmt.Printf("	Size: %d bytes\n", info.Size)
+				fmt.Printf("	Rev: %s\n", info.Rev)
+				fmt.Printf("	Client modified: %s\n", info.ClientModified.Local().Format(timeFormat))
+				fmt.Printf("	Server modified: %s\n", info.ServerModified.Local().Format(timeFormat))
+				fmt.Printf("	Content hash: %s\n", info.ContentHash)
+				fmt.Printf("	Downloadable: %v\n", info.IsDownloadable)
+				if info.SymlinkTarget != "" {
+					fmt.Printf("	Symlink target: %s\n", info.SymlinkTarget)
+				}
+			}
+
+			if sharing := info.Sharing; sharing != nil {
+				fmt.Printf("	Shared: read-only: %v\n", sharing.ReadOnly)
+				if sharing.SharedFolderID != "" {
+					fmt.Printf("	Shared folder ID: %s\n", sharing.SharedFolderID)
+				}
+				if sharing.ParentSharedFolderID != "" {
+					fmt.Printf("	Parent shared folder ID: %s\n", sharing.ParentSharedFolderID)
+				}
+				if sharing.ModifiedBy != "" {
+					fmt.Printf("	Modified by: %s\n", sharing.ModifiedBy)
+				}
+				if sharing.TraverseOnly || sharing.NoAccess {
+					fmt.Printf("	Traverse only: %v, no access: %v\n", sharing.TraverseOnly, sharing.NoAccess)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newHashCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "hash [local_path...]",
+		Short:       "Print the Dropbox content hash of local files",
+		Annotations: map[string]string{skipUnlock: "true"},
+		Long: `Print the Dropbox content hash of one or more local files.
+
+The value can be compared with the content hash of a remote file to check
+that both have the same content. With several files each hash is followed by
+the file name.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printer := getPrinter(cmd)
+			var records []output.Record
+
+			for _, localPath := range args {
+				printVerbose(cmd, "Hashing %s", localPath)
+
+				hash, err := dropbox.FileContentHash(localPath)
+				if err != nil {
+					return err
+				}
+
+				if !printer.IsText() {
+					records = append(records, output.Record{
+						{Name: "path", Value: localPath},
+						{Name: "content_hash", Value: hash},
+					})
+					continue
+				}
+
+				if len(args) == 1 {
+					fmt.Println(hash)
+				} else {
+					fmt.Printf("%s  %s\n", hash, localPath)
+				}
+			}
+
+			if !printer.IsText() {
+				return printer.PrintList(records)
+			}
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newProfilesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "profiles",
+		Short:       "Manage account profiles",
+		Annotations: map[string]string{skipUnlock: "true"},
+		Long: `Manage the accounts saved in the configuration file.
+
+Each profile holds the credentials of one Dropbox account. Commands use the
+default profile unless another one is selected with --profile. Run
+'auth --profile name' to add a profile.`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List saved profiles",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names := configManager.Profiles()
+			defaultProfile := configManager.DefaultProfile()
+
+			printer := getPrinter(cmd)
+			if !printer.IsText() {
+				records := make([]output.Record, len(names))
+				for i, name := range names {
+					records[i] = output.Record{
+						{Name: "name", Value: name},
+						{Name: "default", Value: name == defaultProfile},
+					}
+				}
+				return printer.PrintList(records)
+			}
+
+			if len(names) == 0 {
+				fmt.Println("📂 No profiles - run 'auth' command first")
+				return nil
+			}
+
+			for _, name := range names {
+				if name == defaultProfile {
+					fmt.Printf("👤 %s (default)\n", name)
+				} else {
+					fmt.Printf("👤 %s\n", name)
+				}
+			}
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "use [name]",
+		Short: "Make a profile the default",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			err := configManager.SetDefaultProfile(name)
+			if err != nil {
+				return err
+			}
+			err = configManager.Save()
+			if err != nil {
+				return fmt.Errorf("error saving configuration: %w", err)
+			}
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(output.Record{
+					{Name: "name", Value: name},
+					{Name: "default", Value: true},
+				})
+			}
+
+			fmt.Printf("✅ '%s' is now the default profile\n", name)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:     "remove [name]",
+		Aliases: []string{"rm"},
+		Short:   "Remove a profile and its credentials",
+		Args:    cobra.ExactArgs(1),
+		// Encrypted credentials are sealed again without the removed profile
+		Annotations: map[string]string{skipUnlock: "false"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			err := configManager.RemoveProfile(name)
+			if err != nil {
+				return err
+			}
+			err = configManager.Save()
+			if err != nil {
+				return fmt.Errorf("error saving configuration: %w", err)
+			}
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(output.Record{
+					{Name: "name", Value: name},
+					{Name: "status", Value: "removed"},
+				})
+			}
+
+			fmt.Printf("✅ Removed profile '%s'\n", name)
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Read and change settings",
+		Long: `Read and change the settings in the config file.
+
+Settings are resolved in this order: command line flags, environment
+variables, the config file and finally built-in defaults. Credentials are
+stored per profile, the --profile flag selects which one is changed.
+
+The config file is read from --config, $VALBOKS_CONFIG or
+$XDG_CONFIG_HOME/valboks-cli/config.json (~/.config when unset).
+$VALBOKS_PROFILE selects a profile and $VALBOKS_ACCESS_TOKEN replaces the
+stored access token without changing the file.
+
+Credentials can be encrypted with a passphrase using 'config encrypt'. The
+passphrase is read from $VALBOKS_PASSPHRASE or asked for when needed.
+Credentials are never printed, only whether they are set.`,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:         "path",
+		Short:       "Print the path of the config file",
+		Annotations: map[string]string{skipUnlock: "true"},
+		Args:        cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(output.Record{{Name: "path", Value: configManager.ConfigPath()}})
+			}
+
+			fmt.Println(configManager.ConfigPath())
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List all settings and where their values come from",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printer := getPrinter(cmd)
+			var records []output.Record
+
+			if printer.IsText() {
+				fmt.Printf("📋 Profile: %s\n", configManager.Profile())
+			}
+
+			for _, setting := range config.Settings {
+				value, source, err := configManager.Get(setting.Key)
+				if err != nil {
+					return err
+				}
+				value = displayValue(setting, value)
+
+				if printer.IsText() {
+					fmt.Printf("%-16s %-12s (%s)\n", setting.Key, value, source)
+					continue
+				}
+				records = append(records, output.Record{
+					{Name: "key", Value: setting.Key},
+					{Name: "value", Value: optional(value)},
+					{Name: "source", Value: string(source)},
+				})
+			}
+
+			if !printer.IsText() {
+				return printer.PrintList(records)
+			}
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get [key]",
+		Short: "Print the value of a setting",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			setting, err := config.LookupSetting(args[0])
+			if err != nil {
+				return err
+			}
+			value, source, err := configManager.Get(setting.Key)
+			if err != nil {
+				return err
+			}
+			value = displayValue(setting, value)
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(output.Record{
+					{Name: "key", Value: args[0]},
+					{Name: "value", Value: optional(value)},
+					{Name: "source", Value: string(source)},
+				})
+			}
+
+			fmt.Println(value)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "set [key] [value]",
+		Short: "Change a setting in the config file",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, value := args[0], args[1]
+
+			if key == "output" {
+				_, err := output.ParseFormat(value)
+				if err != nil {
+					return err
+				}
+			}
+
+			err := configManager.Set(key, value)
+			if err != nil {
+				return err
+			}
+			err = configManager.Save()
+			if err != nil {
+				return fmt.Errorf("error saving configuration: %w", err)
+			}
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(output.Record{
+					{Name: "key", Value: key},
+					{Name: "status", Value: "set"},
+				})
+			}
+
+			fmt.Printf("✅ Set '%s'\n", key)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt stored credentials with a passphrase",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if configManager.IsEncrypted() {
+				return fmt.Errorf("credentials are already encrypted")
+			}
+
+			passphrase, err := readPassphrase("New passphrase: ", true)
+			if err != nil {
+				return err
+			}
+
+			err = configManager.Encrypt(passphrase)
+			if err != nil {
+				return err
+			}
+			err = configManager.Save()
+			if err != nil {
+				return fmt.Errorf("error saving configuration: %w", err)
+			}
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(output.Record{{Name: "encrypted", Value: true}})
+			}
+
+			fmt.Println("🔒 Credentials encrypted")
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "decrypt",
+		Short: "Store credentials as plain text again",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := configManager.Decrypt()
+			if err != nil {
+				return err
+			}
+			err = configManager.Save()
+			if err != nil {
+				return fmt.Errorf("error saving configuration: %w", err)
+			}
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(output.Record{{Name: "encrypted", Value: false}})
+			}
+
+			fmt.Println("🔓 Credentials decrypted")
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "unset [key]",
+		Short: "Remove a setting from the config file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := args[0]
+
+			err := configManager.Unset(key)
+			if err != nil {
+				return err
+			}
+			err = configManager.Save()
+			if err != nil {
+				return fmt.Errorf("error saving configuration: %w", err)
+			}
+
+			if printer := getPrinter(cmd); !printer.IsText() {
+				return printer.PrintRecord(output.Record{
+					{Name: "key", Value: key},
+					{Name: "status", Value: "unset"},
+				})
+			}
+
+			fmt.Printf("✅ Unset '%s'\n", key)
+			return nil
+		},
+	})
+
+	return cmd
+}
+
+// displayValue hides the value of secret settings
+func displayValue(setting config.Setting, value string) string {
+	if setting.Secret && value != "" {
+		return "<set>"
+	}
+	return value
+}
+
+// formatSize renders a number of bytes with a binary unit
+func formatSize(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
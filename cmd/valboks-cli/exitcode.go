@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+
+	"valboks/pkg/dropbox"
+)
+
+// Exit codes, listed in the help text of the root command
+const (
+	exitError             = 1
+	exitNotFound          = 3
+	exitConflict          = 4
+	exitInsufficientSpace = 5
+	exitUnauthorized      = 6
+	exitRateLimited       = 7
+	exitMalformedPath     = 8
+)
+
+const exitCodesHelp = `Exit codes:
+  0  success
+  1  any other error
+  3  the path does not exist
+  4  something already exists at the destination
+  5  the account has no space left
+  6  not authenticated, or the access token was rejected
+  7  rate limited by Dropbox after retrying
+  8  Dropbox does not accept the path`
+
+// errNotAuthenticated is returned by commands that need credentials when none are configured
+var errNotAuthenticated = errors.New("not authenticated - run 'auth' command first")
+
+// exitCode returns the exit code for the class of err
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, dropbox.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return exitNotFound
+	case errors.Is(err, dropbox.ErrConflict), errors.Is(err, fs.ErrExist):
+		return exitConflict
+	case errors.Is(err, dropbox.ErrInsufficientSpace):
+		return exitInsufficientSpace
+	case errors.Is(err, dropbox.ErrUnauthorized), errors.Is(err, errNotAuthenticated):
+		return exitUnauthorized
+	case errors.Is(err, dropbox.ErrRateLimited):
+		return exitRateLimited
+	case errors.Is(err, dropbox.ErrMalformedPath):
+		return exitMalformedPath
+	default:
+		return exitError
+	}
+}
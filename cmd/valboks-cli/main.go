@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/spf13/cobra"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 	"valboks/internal/config"
+	"valboks/internal/output"
+	"valboks/pkg/dropbox"
 )
 
 var (
@@ -12,47 +19,151 @@ var (
 	version       = "1.0.0"
 	commit        = "dev"
 	date          = "2025-06-20"
+
+	// cancelTimeout releases the context created for --timeout
+	cancelTimeout context.CancelFunc
 )
 
 func main() {
-	var err error
-
-	configManager, err = config.NewConfigManager()
-	if err != nil {
-		fmt.Printf("Error initializing configuration: %v\n", err)
-		os.Exit(1)
-	}
-
-	err = configManager.Load()
-	if err != nil {
-		fmt.Printf("Error loading configuration: %v\n", err)
-		os.Exit(1)
-	}
-
 	rootCmd := &cobra.Command{
 		Use:   "valboks-cli",
 		Short: "Custom Dropbox CLI tool",
 		Long: `A custom command-line interface tool for accessing Dropbox.
 		
 This tool provides essential Dropbox operations like listing files,
-uploading, downloading, and managing your Dropbox account from the terminal.`,
+uploading, downloading, and managing your Dropbox account from the terminal.
+
+` + exitCodesHelp,
 		Version: fmt.Sprintf("%s (commit: %s, built: %s)", version, commit, date),
+		// Errors are printed below so they can follow the --output format
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// The arguments are valid by now, usage text would not help
+			// with any later error and would make --output unparseable
+			cmd.SilenceUsage = true
+
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			if timeout > 0 {
+				var ctx context.Context
+				ctx, cancelTimeout = context.WithTimeout(cmd.Context(), timeout)
+				cmd.SetContext(ctx)
+			}
+
+			err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			_, err = getOutputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			profile, _ := cmd.Flags().GetString("profile")
+			configManager.UseProfile(profile)
+			return nil
+		},
 	}
 
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Enable verbose output")
+	rootCmd.PersistentFlags().StringP("output", "o", string(output.Text), "Output format: text, json, yaml, csv or tsv")
+	rootCmd.PersistentFlags().String("profile", "", "Account profile to use instead of the default one (env: VALBOKS_PROFILE)")
+	rootCmd.PersistentFlags().String("config", "", "Path of the config file (env: VALBOKS_CONFIG)")
+	rootCmd.PersistentFlags().Duration("timeout", 0, "Give up after this long, for example 30s or 5m (0 means no limit)")
+	rootCmd.PersistentFlags().Int("max-attempts", dropbox.DefaultMaxAttempts, "Times a request is sent before giving up on transient errors (1 disables retries)")
+	rootCmd.PersistentFlags().Duration("retry-budget", dropbox.DefaultRetryBudget, "Longest time one request may spend waiting to be retried")
 
 	rootCmd.AddCommand(newAuthCommand())
+	rootCmd.AddCommand(newLogoutCommand())
+	rootCmd.AddCommand(newAccountCommand())
 	rootCmd.AddCommand(newListCommand())
-	//	rootCmd.AddCommand(newDownloadCommand()) // Due to vibe coding this is not complete
+	rootCmd.AddCommand(newFindCommand())
+	rootCmd.AddCommand(newDiskUsageCommand())
+	rootCmd.AddCommand(newDownloadCommand())
 	rootCmd.AddCommand(newUploadCommand())
 	rootCmd.AddCommand(newDeleteCommand())
-	//	rootCmd.AddCommand(newMkdirCommand()) // Due to vibe coding this is not complete
+	rootCmd.AddCommand(newCopyCommand())
+	rootCmd.AddCommand(newMoveCommand())
+	rootCmd.AddCommand(newMkdirCommand())
 	rootCmd.AddCommand(newInfoCommand())
+	rootCmd.AddCommand(newHashCommand())
+	rootCmd.AddCommand(newProfilesCommand())
+	rootCmd.AddCommand(newConfigCommand())
+
+	// Ctrl-C cancels the running operation, so partial files are left resumable
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if cancelTimeout != nil {
+		cancelTimeout()
+	}
+
+	if err != nil {
+		format, _ := getOutputFormat(rootCmd)
+		if format == output.JSON {
+			output.NewPrinter(format, os.Stderr).PrintError(err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(exitCode(err))
+	}
+}
+
+// loadConfig reads the config file selected with --config, $VALBOKS_CONFIG
+// or the default location
+func loadConfig(cmd *cobra.Command) error {
+	configPath, _ := cmd.Flags().GetString("config")
+
+	var err error
+	configManager, err = config.NewConfigManager(configPath)
+	if err != nil {
+		return fmt.Errorf("error initializing configuration: %w", err)
+	}
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Printf("Error: %v\n, err")
-		os.Exit(1)
+	err = configManager.Load()
+	if err != nil {
+		return fmt.Errorf("error loading configuration: %w", err)
 	}
+
+	return unlockConfig(cmd)
+}
+
+// newClient returns a Dropbox client for the configured account. When a
+// refresh token is stored, expired access tokens are renewed and saved.
+func newClient(cmd *cobra.Command, opts ...dropbox.Option) *dropbox.Client {
+	opts = append([]dropbox.Option{newRetryPolicy(cmd)}, opts...)
+
+	cfg := configManager.GetConfig()
+	if cfg.RefreshToken == "" || cfg.AppKey == "" {
+		return dropbox.NewClient(dropbox.StaticToken(cfg.AccessToken), opts...)
+	}
+
+	tokens := dropbox.NewRefreshingTokenSource(cfg.AppKey, cfg.AppSecret, cfg.AccessToken, cfg.RefreshToken,
+		func(accessToken, refreshToken string) error {
+			configManager.SetTokens(accessToken, refreshToken)
+			return configManager.Save()
+		})
+	return dropbox.NewClient(tokens, opts...)
+}
+
+// newRetryPolicy returns the retry policy selected with --max-attempts and
+// --retry-budget, logging every retry in verbose mode
+func newRetryPolicy(cmd *cobra.Command) dropbox.Option {
+	maxAttempts, _ := cmd.Flags().GetInt("max-attempts")
+	budget, _ := cmd.Flags().GetDuration("retry-budget")
+
+	return dropbox.WithRetryPolicy(dropbox.RetryPolicy{
+		MaxAttempts: maxAttempts,
+		Budget:      budget,
+		OnRetry: func(attempt int, delay time.Duration, err error) {
+			printVerbose(cmd, "Attempt %d of %d failed, retrying in %v: %v", attempt, maxAttempts, delay.Round(time.Millisecond), err)
+		},
+	})
+}
+
+// newSessionStore returns the store that tracks resumable upload sessions
+func newSessionStore() *dropbox.FileSessionStore {
+	return dropbox.NewFileSessionStore(filepath.Join(configManager.ConfigDir(), "uploads.json"))
 }
 
 func getVerbose(cmd *cobra.Command) bool {
@@ -60,8 +171,38 @@ func getVerbose(cmd *cobra.Command) bool {
 	return verbose
 }
 
+// getOutputFormat returns the format from --output, falling back to the
+// format saved in the config file
+func getOutputFormat(cmd *cobra.Command) (output.Format, error) {
+	value, _ := cmd.Flags().GetString("output")
+	if !cmd.Flags().Changed("output") && configManager != nil && configManager.Output() != "" {
+		value = configManager.Output()
+	}
+	if value == "" {
+		return output.Text, nil
+	}
+	return output.ParseFormat(value)
+}
+
+// getPrinter returns the printer for the format selected with --output
+func getPrinter(cmd *cobra.Command) *output.Printer {
+	format, err := getOutputFormat(cmd)
+	if err != nil {
+		format = output.Text
+	}
+	return output.NewPrinter(format, os.Stdout)
+}
+
 func printVerbose(cmd *cobra.Command, format string, args ...interface{}) {
-	if getVerbose(cmd) {
-		fmt.Printf("[VERBOSE] "+format+"\n", args...)
+	if !getVerbose(cmd) {
+		return
 	}
+
+	// Keep machine readable output on stdout parseable
+	if outputFormat, _ := getOutputFormat(cmd); outputFormat != output.Text {
+		fmt.Fprintf(os.Stderr, "[VERBOSE] "+format+"\n", args...)
+		return
+	}
+
+	fmt.Printf("[VERBOSE] "+format+"\n", args...)
 }
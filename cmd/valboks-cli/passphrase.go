@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"valboks/internal/config"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// skipUnlock marks commands that never read credentials, so an encrypted
+// config does not ask for the passphrase. The annotation of the nearest
+// command wins, a subcommand sets it to "false" to require unlocking below
+// a parent that skips it.
+const skipUnlock = "skip-unlock"
+
+// unlockConfig decrypts encrypted credentials unless cmd does not need them
+func unlockConfig(cmd *cobra.Command) error {
+	if !configManager.IsLocked() {
+		return nil
+	}
+
+	for c := cmd; c != nil; c = c.Parent() {
+		if skip, ok := c.Annotations[skipUnlock]; ok {
+			if skip == "true" {
+				return nil
+			}
+			break
+		}
+	}
+
+	passphrase, err := readPassphrase("Passphrase: ", false)
+	if err != nil {
+		return err
+	}
+
+	return configManager.Unlock(passphrase)
+}
+
+// readPassphrase returns $VALBOKS_PASSPHRASE or asks for a passphrase on
+// the terminal. With confirm set it has to be entered twice.
+func readPassphrase(prompt string, confirm bool) (string, error) {
+	if passphrase := os.Getenv(config.EnvPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("credentials are encrypted - set %s or run in a terminal", config.EnvPassphrase)
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("error reading passphrase: %w", err)
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat passphrase: ")
+		repeated, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("error reading passphrase: %w", err)
+		}
+		if string(repeated) != string(passphrase) {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	return string(passphrase), nil
+}
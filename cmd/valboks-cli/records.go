@@ -0,0 +1,72 @@
+package main
+
+import (
+	"valboks/internal/output"
+	"valboks/pkg/dropbox"
+)
+
+// The field names below are part of the CLI's machine readable output and
+// must not be renamed or reordered.
+
+func fileInfoRecord(info *dropbox.FileInfo) output.Record {
+	kind := "file"
+	if info.IsFolder {
+		kind = "folder"
+	}
+
+	record := output.Record{
+		{Name: "name", Value: info.Name},
+		{Name: "path", Value: info.Path},
+		{Name: "path_display", Value: info.PathDisplay},
+		{Name: "id", Value: info.ID},
+		{Name: "type", Value: kind},
+		{Name: "size", Value: info.Size},
+		{Name: "rev", Value: optional(info.Rev)},
+		{Name: "client_modified", Value: info.ClientModified},
+		{Name: "server_modified", Value: info.ServerModified},
+		{Name: "content_hash", Value: optional(info.ContentHash)},
+		{Name: "is_downloadable", Value: !info.IsFolder && info.IsDownloadable},
+		{Name: "symlink_target", Value: optional(info.SymlinkTarget)},
+		{Name: "shared", Value: info.Sharing != nil},
+	}
+
+	sharing := info.Sharing
+	if sharing == nil {
+		sharing = &dropbox.SharingInfo{}
+	}
+
+	return append(record,
+		output.Field{Name: "read_only", Value: sharing.ReadOnly},
+		output.Field{Name: "shared_folder_id", Value: optional(sharing.SharedFolderID)},
+		output.Field{Name: "parent_shared_folder_id", Value: optional(sharing.ParentSharedFolderID)},
+		output.Field{Name: "modified_by", Value: optional(sharing.ModifiedBy)},
+	)
+}
+
+// transferRecord describes the outcome of copying one file or folder
+func transferRecord(source, destination, status string) output.Record {
+	return output.Record{
+		{Name: "source", Value: source},
+		{Name: "destination", Value: destination},
+		{Name: "status", Value: status},
+	}
+}
+
+// folderTransferRecord describes the outcome of copying a folder tree
+func folderTransferRecord(source, destination string, transferred, skipped, failed int) output.Record {
+	return output.Record{
+		{Name: "source", Value: source},
+		{Name: "destination", Value: destination},
+		{Name: "transferred", Value: transferred},
+		{Name: "skipped", Value: skipped},
+		{Name: "failed", Value: failed},
+	}
+}
+
+// optional turns empty strings into nulls
+func optional(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
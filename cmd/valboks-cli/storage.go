@@ -0,0 +1,73 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"valboks/internal/output"
+	"valboks/pkg/dropbox"
+	"valboks/pkg/storage"
+)
+
+const locationHelp = `Paths can be given as dropbox:/path or file:///path URIs, paths without a
+scheme are Dropbox paths.`
+
+// backends opens the backend of each location argument of a command. Every
+// Dropbox location shares one client, so that copies and moves within
+// Dropbox are done by Dropbox itself.
+type backends struct {
+	cmd     *cobra.Command
+	client  *dropbox.Client
+	dropbox *storage.Dropbox
+	local   *storage.Local
+}
+
+func newBackends(cmd *cobra.Command) *backends {
+	return &backends{cmd: cmd}
+}
+
+// open parses arg and returns the backend and path it refers to
+func (b *backends) open(arg string) (storage.Storage, string, error) {
+	return b.openIn(arg, storage.SchemeDropbox)
+}
+
+// openIn is open for an argument whose paths without a scheme are in
+// defaultScheme
+func (b *backends) openIn(arg, defaultScheme string) (storage.Storage, string, error) {
+	location, err := storage.ParseLocation(arg, defaultScheme)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if location.Scheme == storage.SchemeFile {
+		if b.local == nil {
+			b.local = storage.NewLocal()
+		}
+		return b.local, location.Path, nil
+	}
+
+	if !configManager.IsConfigured() {
+		return nil, "", errNotAuthenticated
+	}
+	if b.dropbox == nil {
+		b.client = newClient(b.cmd)
+		b.dropbox = storage.NewDropbox(b.client)
+	}
+	return b.dropbox, location.Path, nil
+}
+
+// storageInfoRecord describes an entry of any backend with the fields of
+// fileInfoRecord, leaving the Dropbox specific ones empty for local files
+func storageInfoRecord(info *storage.FileInfo) output.Record {
+	if dropboxInfo, ok := info.Sys.(*dropbox.FileInfo); ok {
+		return fileInfoRecord(dropboxInfo)
+	}
+
+	return fileInfoRecord(&dropbox.FileInfo{
+		Name:           info.Name,
+		Path:           info.Path,
+		PathDisplay:    info.Path,
+		IsFolder:       info.IsDir,
+		Size:           uint64(info.Size),
+		ClientModified: info.ModTime,
+		IsDownloadable: !info.IsDir,
+	})
+}
@@ -5,8 +5,21 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 )
 
+// DefaultProfileName is used when no profile is selected and none is the default
+const DefaultProfileName = "default"
+
+// Environment variables that override the config file
+const (
+	EnvConfig      = "VALBOKS_CONFIG"
+	EnvProfile     = "VALBOKS_PROFILE"
+	EnvAccessToken = "VALBOKS_ACCESS_TOKEN"
+	EnvPassphrase  = "VALBOKS_PASSPHRASE"
+)
+
+// Config holds the credentials of one profile
 type Config struct {
 	AppKey       string `json:"app_key"`
 	AppSecret    string `json:"app_secret"`
@@ -14,29 +27,63 @@ type Config struct {
 	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
+// configFile is the layout of config.json
+type configFile struct {
+	DefaultProfile string `json:"default_profile,omitempty"`
+	// Output is the output format used when --output is not given
+	Output   string             `json:"output,omitempty"`
+	Profiles map[string]*Config `json:"profiles"`
+	// Credentials is set when the secrets of all profiles are encrypted
+	Credentials *sealedCredentials `json:"credentials,omitempty"`
+}
+
 // ConfigManager this handles loading and saving file configurations
 type ConfigManager struct {
 	configPath string
-	config     *Config
+	file       *configFile
+	profile    string
+	// passphrase is set once encrypted credentials have been unlocked
+	passphrase string
 }
 
-func NewConfigManager() (*ConfigManager, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return nil, fmt.Errorf("error getting home directory: %w", err)
+// DefaultConfigPath returns $VALBOKS_CONFIG if it is set, otherwise
+// config.json in the valboks-cli directory below $XDG_CONFIG_HOME or ~/.config
+func DefaultConfigPath() (string, error) {
+	if path := os.Getenv(EnvConfig); path != "" {
+		return path, nil
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error getting home directory: %w", err)
+		}
+		configHome = filepath.Join(homeDir, ".config")
 	}
 
-	configDir := filepath.Join(homeDir, ".config", "valboks-cli")
-	err = os.MkdirAll(configDir, 0755)
+	return filepath.Join(configHome, "valboks-cli", "config.json"), nil
+}
+
+// NewConfigManager manages the config file at configPath, or at
+// DefaultConfigPath if configPath is empty
+func NewConfigManager(configPath string) (*ConfigManager, error) {
+	if configPath == "" {
+		var err error
+		configPath, err = DefaultConfigPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := os.MkdirAll(filepath.Dir(configPath), 0755)
 	if err != nil {
 		return nil, fmt.Errorf("error creating config directory: %w", err)
 	}
 
-	configPath := filepath.Join(configDir, "config.json")
-
 	return &ConfigManager{
 		configPath: configPath,
-		config:     &Config{},
+		file:       &configFile{Profiles: make(map[string]*Config)},
 	}, nil
 }
 
@@ -50,16 +97,61 @@ func (m *ConfigManager) Load() error {
 		return fmt.Errorf("error reading config file: %w", err)
 	}
 
-	err = json.Unmarshal(data, m.config)
+	var raw map[string]json.RawMessage
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return fmt.Errorf("error parsing config fiel: %w", err)
+	}
+
+	if _, ok := raw["profiles"]; !ok {
+		return m.migrate(data)
+	}
+
+	err = json.Unmarshal(data, m.file)
 	if err != nil {
 		return fmt.Errorf("error parsing config fiel: %w", err)
 	}
+	if m.file.Profiles == nil {
+		m.file.Profiles = make(map[string]*Config)
+	}
 
 	return nil
 }
 
+// migrate converts a config file from before profiles existed into a file
+// with a single default profile and saves it
+func (m *ConfigManager) migrate(data []byte) error {
+	legacy := &Config{}
+	err := json.Unmarshal(data, legacy)
+	if err != nil {
+		return fmt.Errorf("error parsing config fiel: %w", err)
+	}
+
+	m.file.Profiles[DefaultProfileName] = legacy
+	m.file.DefaultProfile = DefaultProfileName
+
+	err = m.Save()
+	if err != nil {
+		return fmt.Errorf("error migrating config file to profiles: %w", err)
+	}
+
+	return nil
+}
+
+// Save writes the config file. Once encrypted credentials are unlocked
+// they are encrypted again with a new salt, while they are locked the
+// stored ciphertext is kept as it is.
 func (m *ConfigManager) Save() error {
-	data, err := json.MarshalIndent(m.config, "", "  ")
+	file := m.file
+	if m.passphrase != "" {
+		var err error
+		file, err = m.sealed()
+		if err != nil {
+			return fmt.Errorf("error encrypting credentials: %w", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
 	if err != nil {
 		return fmt.Errorf("error serializing config: %w", err)
 	}
@@ -72,21 +164,234 @@ func (m *ConfigManager) Save() error {
 	return nil
 }
 
+// ConfigPath returns the path of the config file
+func (m *ConfigManager) ConfigPath() string {
+	return m.configPath
+}
+
+// ConfigDir returns the directory holding the config file and other local state
+func (m *ConfigManager) ConfigDir() string {
+	return filepath.Dir(m.configPath)
+}
+
+// UseProfile selects the profile GetConfig and the setters work on. An
+// empty name falls back to $VALBOKS_PROFILE and then the default profile.
+func (m *ConfigManager) UseProfile(name string) {
+	m.profile = name
+}
+
+// Profile returns the name of the selected profile
+func (m *ConfigManager) Profile() string {
+	if m.profile != "" {
+		return m.profile
+	}
+	if name := os.Getenv(EnvProfile); name != "" {
+		return name
+	}
+	return m.DefaultProfile()
+}
+
+// DefaultProfile returns the profile used when none is selected
+func (m *ConfigManager) DefaultProfile() string {
+	if m.file.DefaultProfile != "" {
+		return m.file.DefaultProfile
+	}
+	return DefaultProfileName
+}
+
+// SetDefaultProfile makes an existing profile the default
+func (m *ConfigManager) SetDefaultProfile(name string) error {
+	if _, ok := m.file.Profiles[name]; !ok {
+		return fmt.Errorf("profile '%s' does not exist", name)
+	}
+	m.file.DefaultProfile = name
+	return nil
+}
+
+// Profiles returns the names of all saved profiles in alphabetical order
+func (m *ConfigManager) Profiles() []string {
+	names := make([]string, 0, len(m.file.Profiles))
+	for name := range m.file.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasProfile reports whether a profile with the given name is saved
+func (m *ConfigManager) HasProfile(name string) bool {
+	_, ok := m.file.Profiles[name]
+	return ok
+}
+
+// RemoveProfile deletes a profile. Removing the default profile leaves no
+// default. Encrypted credentials must be unlocked first, otherwise the
+// secrets of the profile would stay in the stored ciphertext.
+func (m *ConfigManager) RemoveProfile(name string) error {
+	if _, ok := m.file.Profiles[name]; !ok {
+		return fmt.Errorf("profile '%s' does not exist", name)
+	}
+	if m.IsLocked() {
+		return fmt.Errorf("credentials must be unlocked before a profile can be removed")
+	}
+	delete(m.file.Profiles, name)
+	if m.file.DefaultProfile == name {
+		m.file.DefaultProfile = ""
+	}
+	return nil
+}
+
+// GetConfig returns the effective config of the selected profile, with
+// $VALBOKS_ACCESS_TOKEN taking precedence over the stored tokens. Changes
+// to the returned value are not saved, use the setters instead.
 func (m *ConfigManager) GetConfig() *Config {
-	return m.config
+	config := Config{}
+	if stored, ok := m.file.Profiles[m.Profile()]; ok {
+		config = *stored
+	}
+
+	if token := os.Getenv(EnvAccessToken); token != "" {
+		config.AccessToken = token
+		// The stored refresh token belongs to a different access token
+		config.RefreshToken = ""
+	}
+
+	return &config
+}
+
+// profileConfig returns the stored config of the selected profile, creating it if needed
+func (m *ConfigManager) profileConfig() *Config {
+	name := m.Profile()
+	config, ok := m.file.Profiles[name]
+	if !ok {
+		config = &Config{}
+		m.file.Profiles[name] = config
+	}
+	return config
 }
 
 func (m *ConfigManager) SetCredentials(appKey, appSecret, accessToken string) {
-	m.config.AppKey = appKey
-	m.config.AppSecret = appSecret
-	m.config.AccessToken = accessToken
+	config := m.profileConfig()
+	config.AppKey = appKey
+	config.AppSecret = appSecret
+	config.AccessToken = accessToken
+
+	if m.file.DefaultProfile == "" {
+		m.file.DefaultProfile = m.Profile()
+	}
 }
 
 func (m *ConfigManager) SetTokens(accessToken, refreshToken string) {
-	m.config.AccessToken = accessToken
-	m.config.RefreshToken = refreshToken
+	config := m.profileConfig()
+	config.AccessToken = accessToken
+	config.RefreshToken = refreshToken
+}
+
+// ClearCredentials removes the app secret and tokens of the selected
+// profile, keeping the profile and its app key
+func (m *ConfigManager) ClearCredentials() {
+	config, ok := m.file.Profiles[m.Profile()]
+	if !ok {
+		return
+	}
+	config.AppSecret = ""
+	config.AccessToken = ""
+	config.RefreshToken = ""
 }
 
 func (m *ConfigManager) IsConfigured() bool {
-	return m.config.AccessToken != ""
+	return m.GetConfig().AccessToken != ""
+}
+
+// Output returns the output format saved in the config file, if any
+func (m *ConfigManager) Output() string {
+	return m.file.Output
+}
+
+// IsEncrypted reports whether credentials are stored encrypted
+func (m *ConfigManager) IsEncrypted() bool {
+	return m.passphrase != "" || m.file.Credentials != nil
+}
+
+// IsLocked reports whether encrypted credentials still need to be unlocked
+func (m *ConfigManager) IsLocked() bool {
+	return m.file.Credentials != nil
+}
+
+// Unlock decrypts the stored credentials, keeping the passphrase to
+// encrypt them again on Save
+func (m *ConfigManager) Unlock(passphrase string) error {
+	if m.file.Credentials == nil {
+		return nil
+	}
+
+	plaintext, err := m.file.Credentials.open(passphrase)
+	if err != nil {
+		return err
+	}
+
+	for name, secret := range plaintext {
+		config, ok := m.file.Profiles[name]
+		if !ok {
+			continue
+		}
+		config.AppSecret = secret.AppSecret
+		config.AccessToken = secret.AccessToken
+		config.RefreshToken = secret.RefreshToken
+	}
+
+	m.file.Credentials = nil
+	m.passphrase = passphrase
+	return nil
+}
+
+// Encrypt makes Save store credentials encrypted with passphrase
+func (m *ConfigManager) Encrypt(passphrase string) error {
+	if m.IsEncrypted() {
+		return fmt.Errorf("credentials are already encrypted")
+	}
+	if passphrase == "" {
+		return fmt.Errorf("passphrase cannot be empty")
+	}
+
+	m.passphrase = passphrase
+	return nil
+}
+
+// Decrypt makes Save store unlocked credentials as plain text again
+func (m *ConfigManager) Decrypt() error {
+	if !m.IsEncrypted() {
+		return fmt.Errorf("credentials are not encrypted")
+	}
+	if m.IsLocked() {
+		return fmt.Errorf("credentials must be unlocked before they can be decrypted")
+	}
+
+	m.passphrase = ""
+	return nil
+}
+
+// sealed returns a copy of the config file with the secrets of every
+// profile moved into encrypted credentials
+func (m *ConfigManager) sealed() (*configFile, error) {
+	file := *m.file
+	file.Profiles = make(map[string]*Config, len(m.file.Profiles))
+	plaintext := make(map[string]secrets, len(m.file.Profiles))
+
+	for name, config := range m.file.Profiles {
+		plaintext[name] = secrets{
+			AppSecret:    config.AppSecret,
+			AccessToken:  config.AccessToken,
+			RefreshToken: config.RefreshToken,
+		}
+		file.Profiles[name] = &Config{AppKey: config.AppKey}
+	}
+
+	credentials, err := seal(m.passphrase, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	file.Credentials = credentials
+	return &file, nil
 }
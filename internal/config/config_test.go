@@ -0,0 +1,88 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// newEncryptedConfig saves a config with two profiles encrypted with
+// passphrase and returns its path
+func newEncryptedConfig(t *testing.T, passphrase string) string {
+	t.Helper()
+
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	m, err := NewConfigManager(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"work", "home"} {
+		m.UseProfile(name)
+		m.SetCredentials(name+"-key", name+"-secret", name+"-access")
+		m.SetTokens(name+"-access", name+"-refresh")
+	}
+	if err := m.Encrypt(passphrase); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	return configPath
+}
+
+func loadConfig(t *testing.T, configPath string) *ConfigManager {
+	t.Helper()
+
+	m, err := NewConfigManager(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestRemoveProfileEncrypted(t *testing.T) {
+	const passphrase = "correct horse"
+	configPath := newEncryptedConfig(t, passphrase)
+
+	locked := loadConfig(t, configPath)
+	if err := locked.RemoveProfile("work"); err == nil {
+		t.Fatal("RemoveProfile succeeded on locked credentials")
+	}
+
+	m := loadConfig(t, configPath)
+	if err := m.Unlock(passphrase); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := m.RemoveProfile("work"); err != nil {
+		t.Fatalf("RemoveProfile: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded := loadConfig(t, configPath)
+	plaintext, err := reloaded.file.Credentials.open(passphrase)
+	if err != nil {
+		t.Fatalf("opening the stored credentials: %v", err)
+	}
+	if _, ok := plaintext["work"]; ok {
+		t.Error("the secrets of the removed profile are still stored")
+	}
+
+	if err := reloaded.Unlock(passphrase); err != nil {
+		t.Fatalf("Unlock after removing: %v", err)
+	}
+	if reloaded.HasProfile("work") {
+		t.Error("removed profile is still saved")
+	}
+
+	reloaded.UseProfile("home")
+	config := reloaded.GetConfig()
+	if config.AppSecret != "home-secret" || config.AccessToken != "home-access" || config.RefreshToken != "home-refresh" {
+		t.Errorf("remaining profile = %+v, want its secrets unchanged", config)
+	}
+}
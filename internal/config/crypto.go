@@ -0,0 +1,113 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrWrongPassphrase is returned when encrypted credentials cannot be decrypted
+var ErrWrongPassphrase = errors.New("wrong passphrase for encrypted credentials")
+
+// scrypt parameters for newly encrypted files, the ones used are stored
+// next to the ciphertext so they can be raised later
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+// sealedCredentials holds the secrets of every profile encrypted with
+// AES-256-GCM under a key derived from a passphrase with scrypt
+type sealedCredentials struct {
+	KDF   string `json:"kdf"`
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// secrets are the parts of a profile that are only stored encrypted
+type secrets struct {
+	AppSecret    string `json:"app_secret,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+func seal(passphrase string, plaintext map[string]secrets) (*sealedCredentials, error) {
+	data, err := json.Marshal(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := &sealedCredentials{
+		KDF:   "scrypt",
+		N:     scryptN,
+		R:     scryptR,
+		P:     scryptP,
+		Salt:  make([]byte, 16),
+		Nonce: make([]byte, 12),
+	}
+	_, err = rand.Read(sealed.Salt)
+	if err != nil {
+		return nil, err
+	}
+	_, err = rand.Read(sealed.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := sealed.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed.Data = aead.Seal(nil, sealed.Nonce, data, nil)
+	return sealed, nil
+}
+
+func (s *sealedCredentials) open(passphrase string) (map[string]secrets, error) {
+	aead, err := s.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := aead.Open(nil, s.Nonce, s.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	plaintext := make(map[string]secrets)
+	err = json.Unmarshal(data, &plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing encrypted credentials: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func (s *sealedCredentials) aead(passphrase string) (cipher.AEAD, error) {
+	if s.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported key derivation function '%s'", s.KDF)
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), s.Salt, s.N, s.R, s.P, scryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving encryption key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
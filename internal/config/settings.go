@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// Source tells where the effective value of a setting comes from
+type Source string
+
+const (
+	SourceEnv     Source = "env"
+	SourceFile    Source = "file"
+	SourceDefault Source = "default"
+)
+
+// Setting is a key that can be read and changed with Get, Set and Unset
+type Setting struct {
+	Key string
+	// Profile is set for keys stored per profile rather than once per file
+	Profile bool
+	// Secret is set for credentials that should not be shown by default
+	Secret bool
+	// Env is the environment variable overriding the key, if any
+	Env string
+}
+
+// Settings lists every key in the order they are shown
+var Settings = []Setting{
+	{Key: "default_profile"},
+	{Key: "output"},
+	{Key: "app_key", Profile: true},
+	{Key: "app_secret", Profile: true, Secret: true},
+	{Key: "access_token", Profile: true, Secret: true, Env: EnvAccessToken},
+	{Key: "refresh_token", Profile: true, Secret: true},
+}
+
+// LookupSetting returns the setting with the given key
+func LookupSetting(key string) (Setting, error) {
+	for _, setting := range Settings {
+		if setting.Key == key {
+			return setting, nil
+		}
+	}
+	return Setting{}, fmt.Errorf("unknown config key '%s'", key)
+}
+
+// Get returns the effective value of key and where it comes from. Per
+// profile keys are read from the selected profile.
+func (m *ConfigManager) Get(key string) (string, Source, error) {
+	setting, err := LookupSetting(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	if setting.Env != "" {
+		if value := os.Getenv(setting.Env); value != "" {
+			return value, SourceEnv, nil
+		}
+	}
+
+	field := m.field(key, false)
+	if field == nil || *field == "" {
+		if key == "default_profile" {
+			return DefaultProfileName, SourceDefault, nil
+		}
+		return "", SourceDefault, nil
+	}
+
+	return *field, SourceFile, nil
+}
+
+// Set stores value for key, per profile keys in the selected profile
+func (m *ConfigManager) Set(key, value string) error {
+	_, err := LookupSetting(key)
+	if err != nil {
+		return err
+	}
+
+	if key == "default_profile" {
+		return m.SetDefaultProfile(value)
+	}
+
+	*m.field(key, true) = value
+	return nil
+}
+
+// Unset removes key from the config file
+func (m *ConfigManager) Unset(key string) error {
+	_, err := LookupSetting(key)
+	if err != nil {
+		return err
+	}
+
+	if field := m.field(key, false); field != nil {
+		*field = ""
+	}
+	return nil
+}
+
+// field returns the stored value of key. For per profile keys it returns
+// nil if the selected profile does not exist, unless create is set.
+func (m *ConfigManager) field(key string, create bool) *string {
+	switch key {
+	case "default_profile":
+		return &m.file.DefaultProfile
+	case "output":
+		return &m.file.Output
+	}
+
+	config, ok := m.file.Profiles[m.Profile()]
+	if !ok {
+		if !create {
+			return nil
+		}
+		config = m.profileConfig()
+	}
+
+	switch key {
+	case "app_key":
+		return &config.AppKey
+	case "app_secret":
+		return &config.AppSecret
+	case "access_token":
+		return &config.AccessToken
+	case "refresh_token":
+		return &config.RefreshToken
+	default:
+		return nil
+	}
+}
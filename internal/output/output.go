@@ -0,0 +1,260 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is an output format selectable with the --output flag
+type Format string
+
+const (
+	Text Format = "text"
+	JSON Format = "json"
+	YAML Format = "yaml"
+	CSV  Format = "csv"
+	TSV  Format = "tsv"
+)
+
+// Formats lists every supported format
+var Formats = []Format{Text, JSON, YAML, CSV, TSV}
+
+func ParseFormat(value string) (Format, error) {
+	for _, format := range Formats {
+		if strings.EqualFold(value, string(format)) {
+			return format, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown output format '%s' (expected one of text, json, yaml, csv, tsv)", value)
+}
+
+// Field is a named value in a Record
+type Field struct {
+	Name  string
+	Value any
+}
+
+// Record is a flat set of fields whose names and order stay the same
+// between releases, so scripts can rely on them
+type Record []Field
+
+// Printer renders command results in a machine readable format.
+// In Text format commands print their own human readable output instead.
+type Printer struct {
+	format Format
+	w      io.Writer
+}
+
+func NewPrinter(format Format, w io.Writer) *Printer {
+	return &Printer{format: format, w: w}
+}
+
+func (p *Printer) Format() Format {
+	return p.format
+}
+
+// IsText reports whether the command should print its human readable output
+func (p *Printer) IsText() bool {
+	return p.format == Text
+}
+
+// PrintRecord renders a single result
+func (p *Printer) PrintRecord(record Record) error {
+	switch p.format {
+	case JSON:
+		data, err := marshalRecord(record)
+		if err != nil {
+			return err
+		}
+		return p.writeJSON(data)
+	case YAML:
+		return p.writeYAMLRecord(record, "")
+	default:
+		return p.PrintList([]Record{record})
+	}
+}
+
+// PrintList renders a list of results. All records are expected to have the same fields.
+func (p *Printer) PrintList(records []Record) error {
+	switch p.format {
+	case JSON:
+		items := make([]json.RawMessage, len(records))
+		for i, record := range records {
+			data, err := marshalRecord(record)
+			if err != nil {
+				return err
+			}
+			items[i] = data
+		}
+		data, err := json.Marshal(items)
+		if err != nil {
+			return err
+		}
+		return p.writeJSON(data)
+	case YAML:
+		if len(records) == 0 {
+			_, err := fmt.Fprintln(p.w, "[]")
+			return err
+		}
+		for _, record := range records {
+			err := p.writeYAMLRecord(record, "- ")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case CSV, TSV:
+		return p.writeTable(records)
+	default:
+		return fmt.Errorf("format '%s' cannot render records", p.format)
+	}
+}
+
+// PrintError renders an error as a record with an "error" field
+func (p *Printer) PrintError(err error) error {
+	return p.PrintRecord(Record{{"error", err.Error()}})
+}
+
+func (p *Printer) writeJSON(data []byte) error {
+	var buf bytes.Buffer
+	err := json.Indent(&buf, data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(p.w)
+	return err
+}
+
+// writeYAMLRecord writes a record as a YAML mapping. prefix is written
+// before the first field, which turns the mapping into a list item.
+func (p *Printer) writeYAMLRecord(record Record, prefix string) error {
+	indent := strings.Repeat(" ", len(prefix))
+
+	for i, field := range record {
+		value, err := yamlScalar(field.Value)
+		if err != nil {
+			return err
+		}
+
+		lead := indent
+		if i == 0 {
+			lead = prefix
+		}
+
+		_, err = fmt.Fprintf(p.w, "%s%s: %s\n", lead, field.Name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Printer) writeTable(records []Record) error {
+	writer := csv.NewWriter(p.w)
+	if p.format == TSV {
+		writer.Comma = '\t'
+	}
+
+	if len(records) > 0 {
+		header := make([]string, len(records[0]))
+		for i, field := range records[0] {
+			header[i] = field.Name
+		}
+		writer.Write(header)
+	}
+
+	for _, record := range records {
+		row := make([]string, len(record))
+		for i, field := range record {
+			row[i] = textValue(field.Value)
+		}
+		writer.Write(row)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// marshalRecord encodes a record as a JSON object keeping the field order
+func marshalRecord(record Record) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	for i, field := range record {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		name, err := json.Marshal(field.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(normalize(field.Value))
+		if err != nil {
+			return nil, fmt.Errorf("error encoding field '%s': %w", field.Name, err)
+		}
+
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// yamlScalar renders a value as a YAML scalar. Strings use the double
+// quoted style, which accepts JSON string escapes.
+func yamlScalar(value any) (string, error) {
+	value = normalize(value)
+
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		data, err := json.Marshal(v)
+		return string(data), err
+	default:
+		return textValue(v), nil
+	}
+}
+
+// textValue renders a value for CSV and TSV cells
+func textValue(value any) string {
+	switch v := normalize(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// normalize converts values without a stable textual form, such as times,
+// into the representation used by every format
+func normalize(value any) any {
+	switch v := value.(type) {
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+		return v.UTC().Format(time.RFC3339)
+	case error:
+		return v.Error()
+	default:
+		return value
+	}
+}
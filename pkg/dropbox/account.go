@@ -0,0 +1,141 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/common"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/users"
+)
+
+// AccountInfo describes the account the client is authorized for
+type AccountInfo struct {
+	AccountID     string
+	Name          string
+	Email         string
+	EmailVerified bool
+	// AccountType is basic, pro or business
+	AccountType string
+	Country     string
+	Locale      string
+
+	// The team fields are only set for members of a team
+	TeamID       string
+	TeamName     string
+	TeamMemberID string
+
+	// RootNamespaceID is the namespace paths are resolved in. For members of
+	// a team with a shared team root it differs from HomeNamespaceID.
+	RootNamespaceID string
+	HomeNamespaceID string
+}
+
+// SpaceUsage describes how much storage an account uses and may use
+type SpaceUsage struct {
+	// Used is the space used by the account itself
+	Used uint64
+	// Allocated is the space available to the account, or to its whole
+	// team if Team is set. It is zero if Dropbox did not report a limit.
+	Allocated uint64
+	// Team is set when the account shares its space with a team
+	Team bool
+	// TeamUsed is the space used by the whole team
+	TeamUsed uint64
+	// MemberLimit is the part of the team space the account may use, zero if unlimited
+	MemberLimit uint64
+}
+
+// Free returns the number of bytes that can still be stored
+func (s *SpaceUsage) Free() uint64 {
+	used := s.Used
+	if s.Team {
+		used = s.TeamUsed
+	}
+	free := remaining(s.Allocated, used)
+
+	if s.Team && s.MemberLimit > 0 {
+		free = min(free, remaining(s.MemberLimit, s.Used))
+	}
+
+	return free
+}
+
+func remaining(allocated, used uint64) uint64 {
+	if used >= allocated {
+		return 0
+	}
+	return allocated - used
+}
+
+// GetAccount returns the account the access token belongs to
+func (c *Client) GetAccount(ctx context.Context) (*AccountInfo, error) {
+	var account *users.FullAccount
+	err := c.retry(ctx, func() (err error) {
+		account, err = c.users(ctx).GetCurrentAccount()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current account: %w", classify("", err))
+	}
+
+	info := &AccountInfo{
+		AccountID:     account.AccountId,
+		Email:         account.Email,
+		EmailVerified: account.EmailVerified,
+		Country:       account.Country,
+		Locale:        account.Locale,
+		TeamMemberID:  account.TeamMemberId,
+	}
+	if account.Name != nil {
+		info.Name = account.Name.DisplayName
+	}
+	if account.AccountType != nil {
+		info.AccountType = account.AccountType.Tag
+	}
+	if account.Team != nil {
+		info.TeamID = account.Team.Id
+		info.TeamName = account.Team.Name
+	}
+
+	switch root := account.RootInfo.(type) {
+	case *common.TeamRootInfo:
+		info.RootNamespaceID = root.RootNamespaceId
+		info.HomeNamespaceID = root.HomeNamespaceId
+	case *common.UserRootInfo:
+		info.RootNamespaceID = root.RootNamespaceId
+		info.HomeNamespaceID = root.HomeNamespaceId
+	}
+
+	return info, nil
+}
+
+// GetSpaceUsage returns the storage used and allocated for the account
+func (c *Client) GetSpaceUsage(ctx context.Context) (*SpaceUsage, error) {
+	var usage *users.SpaceUsage
+	err := c.retry(ctx, func() (err error) {
+		usage, err = c.users(ctx).GetSpaceUsage()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get space usage: %w", classify("", err))
+	}
+
+	result := &SpaceUsage{Used: usage.Used}
+	if allocation := usage.Allocation; allocation != nil {
+		switch allocation.Tag {
+		case users.SpaceAllocationIndividual:
+			if allocation.Individual != nil {
+				result.Allocated = allocation.Individual.Allocated
+			}
+		case users.SpaceAllocationTeam:
+			if allocation.Team != nil {
+				result.Team = true
+				result.Allocated = allocation.Team.Allocated
+				result.TeamUsed = allocation.Team.Used
+				result.MemberLimit = allocation.Team.UserWithinTeamSpaceAllocated
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -1,209 +1,300 @@
 package dropbox
 
 import (
+	"context"
 	"fmt"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
 	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
-	"io"
-	"os"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/users"
+	"iter"
+	"net/http"
 	"strings"
 )
 
 type Client struct {
-	filesClient files.Client
+	config dropbox.Config
+	// httpClient is copied for every SDK client, with transport authorizing its requests
+	httpClient *http.Client
+	// transport authorizes requests, SDK clients are created per call to bind them to a context
+	transport   http.RoundTripper
+	chunkSize   int64
+	sessions    SessionStore
+	retryPolicy RetryPolicy
 }
 
-type FileInfo struct {
-	Name string
-	Path string
-	IsFolder bool
-	Size uint64
-}
+// Option configures optional Client behaviour
+type Option func(*Client)
 
-func NewClient(accessToken string) *Client {
-	config := dropbox.Config{
-		Token: accessToken,
-		LogLevel: dropbox.LogOff,
+// WithChunkSize sets the number of bytes sent per request when uploading
+// files through an upload session. Non-positive values keep the default.
+func WithChunkSize(size int64) Option {
+	return func(c *Client) {
+		if size > 0 {
+			c.chunkSize = size
+		}
 	}
+}
 
-	return &Client{
-		filesClient: files.New(config),
+// WithSessionStore records upload session progress in store so that
+// interrupted uploads can be resumed by a later call to UploadFile.
+func WithSessionStore(store SessionStore) Option {
+	return func(c *Client) {
+		c.sessions = store
 	}
 }
 
-func (c *Client) ListFolder(path string) ([]FileInfo, error) {
-
-	path = normalizePath(path)
-
-	listArg := files.NewListFolderArg(path)
-	result, err := c.filesClient.ListFolder(listArg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list folder '%s': %w", path, err)
+// WithDomain sends requests to the API hosts under domain instead of
+// .dropboxapi.com, for example ".dropboxapi.example" for api.dropboxapi.example
+func WithDomain(domain string) Option {
+	return func(c *Client) {
+		c.config.Domain = domain
 	}
+}
 
-	var fileInfos []FileInfo
-
-	fileInfos = append(fileInfos, c.processEntries(result.Entries)...)
+// WithURLGenerator builds the URL of every request with generate, which is
+// given the host type ("api" or "content"), the namespace and the route.
+// It takes precedence over WithDomain.
+func WithURLGenerator(generate func(hostType, namespace, route string) string) Option {
+	return func(c *Client) {
+		c.config.URLGenerator = generate
+	}
+}
 
-	for result.HasMore {
-		continueArg := files.NewListFolderContinueArg(result.Cursor)
-		result, err = c.filesClient.ListFolderContinue(continueArg)
-		if err != nil {
-			return nil, fmt.Errorf("failed to continue listing folder: %w", err)
+// WithHTTPClient sends requests through client. Its transport is wrapped to
+// add authorization, the default transport is used if it has none.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		if client != nil {
+			c.httpClient = client
 		}
-
-		fileInfos = append(fileInfos, c.processEntries(result.Entries)...)
 	}
-
-	return fileInfos, nil
 }
 
-//processEntries converts Dropbox API entries to FileInfo structs
-func (c *Client) processEntries(entries []files.IsMetadata) []FileInfo {
-	var fileInfos []FileInfo
+// NewClient returns a client authorized by tokens. Expired access tokens
+// are refreshed and the failed request is retried transparently, requests
+// failing for transient reasons are retried according to the retry policy.
+func NewClient(tokens TokenSource, opts ...Option) *Client {
+	c := &Client{
+		config:      dropbox.Config{LogLevel: dropbox.LogOff},
+		httpClient:  &http.Client{},
+		chunkSize:   DefaultChunkSize,
+		retryPolicy: DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
 
-	for _, entry := range entries {
-		switch e := entry.(type) {
-		case *files.FolderMetadata:
-			fileInfos = append(fileInfos, FileInfo{
-			Name: e.Name,
-			Path: e.PathLower,
-			IsFolder: true,
-			Size: 0,
-			})
-		case *files.FileMetadata:
-			fileInfos = append(fileInfos, FileInfo{
-			Name: e.Name,
-			Path: e.PathLower,
-			IsFolder: false,
-			Size: e.Size,
-			})
-		}
+	base := c.httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
 	}
+	c.transport = &tokenTransport{tokens: tokens, base: base}
 
-	return fileInfos
+	return c
 }
 
-//downloading file from dropbox to local path
-func (c *Client) DownloadFile(dropboxPath, localPath string) error {
-
-	dropboxPath = normalizePath(dropboxPath)
-
-	downloadArg := files.NewDownloadArg(dropboxPath)
-	_, content, err := c.filesClient.Download(downloadArg)
-	if err != nil {
-		return fmt.Errorf("failed to download file '%s': %w", dropboxPath, err)
-	}
-	defer content.Close()
+// contextTransport sends every request as part of ctx, which the SDK has no way to pass
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
 
-	outFile, err := os.Create(localPath)
-	if err != nil {
-		return fmt.Errorf("failed to create local file '%s': %w", localPath, err)
-	}
-	defer  outFile.Close()
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
 
-	//Copy content to file
-	_, err = io.Copy(outFile, content)
-	if err != nil {
-		return fmt.Errorf("failed to write file content: '%w'", err)
-	}
+// sdkConfig returns the SDK configuration for requests that stop when ctx is done
+func (c *Client) sdkConfig(ctx context.Context) dropbox.Config {
+	client := *c.httpClient
+	client.Transport = &contextTransport{ctx: ctx, base: c.transport}
 
-	return nil
+	config := c.config
+	config.Client = &client
+	return config
 }
 
+func (c *Client) files(ctx context.Context) files.Client {
+	return files.New(c.sdkConfig(ctx))
+}
 
-func (c *Client) UploadFile(localPath, dropboxPath string, overwrite bool) error {
+func (c *Client) auth(ctx context.Context) auth.Client {
+	return auth.New(c.sdkConfig(ctx))
+}
 
-	dropboxPath = normalizePath(dropboxPath)
+func (c *Client) users(ctx context.Context) users.Client {
+	return users.New(c.sdkConfig(ctx))
+}
 
-	file, err := os.Open(localPath)
-	if err != nil {
-		return fmt.Errorf("failed to open local file '%s': %w", localPath, err)
-	}
-	defer file.Close()
+// ListFolder returns every entry of the folder at path. Use ListFolderIter
+// to process large folders without holding all of their entries.
+func (c *Client) ListFolder(ctx context.Context, path string) ([]FileInfo, error) {
+	return c.listFolder(ctx, path, false)
+}
 
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to get the file info: %w", err)
-	}
+// listFolder lists the folder at path, including everything below it if recursive is set
+func (c *Client) listFolder(ctx context.Context, path string, recursive bool) ([]FileInfo, error) {
+	var fileInfos []FileInfo
 
-	commitInfo := files.NewCommitInfo(dropboxPath)
-	if overwrite {
-		commitInfo.Mode = &files.WriteMode{Tagged: dropbox.Tagged{Tag: "overwrite"}}
+	for info, err := range c.ListFolderIter(ctx, path, recursive) {
+		if err != nil {
+			return nil, err
+		}
+		fileInfos = append(fileInfos, info)
 	}
 
-	uploadArg := files.NewUploadArg(commitInfo.Path)
+	return fileInfos, nil
+}
 
-	if fileInfo.Size() < 150*1024*1024 {
-		_, err = c.filesClient.Upload(uploadArg, file)
+// ListFolderIter yields the entries of the folder at path as each page of
+// the listing arrives. With recursive set everything below path is listed
+// too, and for folders other than the root Dropbox yields the folder itself
+// first. Iteration ends after the first error.
+func (c *Client) ListFolderIter(ctx context.Context, path string, recursive bool) iter.Seq2[FileInfo, error] {
+	return func(yield func(FileInfo, error) bool) {
+		path := normalizePath(path)
+
+		listArg := files.NewListFolderArg(path)
+		listArg.Recursive = recursive
+		filesClient := c.files(ctx)
+		var result *files.ListFolderResult
+		err := c.retry(ctx, func() (err error) {
+			result, err = filesClient.ListFolder(listArg)
+			return err
+		})
 		if err != nil {
-			return fmt.Errorf("failed to upload file '%s': %w", localPath, err)
+			yield(FileInfo{}, fmt.Errorf("failed to list folder '%s': %w", path, classify(path, err)))
+			return
 		}
-	} else {
-		return fmt.Errorf("files lager than 150MB are not supported in this version")
-	}
 
-	return nil
+		for {
+			for _, entry := range result.Entries {
+				if info := newFileInfo(entry); info != nil && !yield(*info, nil) {
+					return
+				}
+			}
+
+			if !result.HasMore {
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				yield(FileInfo{}, fmt.Errorf("failed to list folder '%s': %w", path, err))
+				return
+			}
+
+			continueArg := files.NewListFolderContinueArg(result.Cursor)
+			err = c.retry(ctx, func() (err error) {
+				result, err = filesClient.ListFolderContinue(continueArg)
+				return err
+			})
+			if err != nil {
+				yield(FileInfo{}, fmt.Errorf("failed to continue listing folder: %w", classify(path, err)))
+				return
+			}
+		}
+	}
 }
 
-func (c * Client) DeletePath(path string) error {
+func (c *Client) DeletePath(ctx context.Context, path string) error {
 
 	path = normalizePath(path)
 
 	deleteArg := files.NewDeleteArg(path)
-	_, err := c.filesClient.DeleteV2(deleteArg)
+	err := c.retry(ctx, func() error {
+		_, err := c.files(ctx).DeleteV2(deleteArg)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete '%s': %w", path, err)
+		return fmt.Errorf("failed to delete '%s': %w", path, classify(path, err))
 	}
 
 	return nil
 }
 
-func (c *Client) CreateFolder(path string) error {
+func (c *Client) CreateFolder(ctx context.Context, path string) error {
 
 	path = normalizePath(path)
 
 	createArg := files.NewCreateFolderArg(path)
-	_, err := c.filesClient.CreateFolderV2(createArg)
+	err := c.retry(ctx, func() error {
+		_, err := c.files(ctx).CreateFolderV2(createArg)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create a folder '%s': %w", path, err)
+		return fmt.Errorf("failed to create a folder '%s': %w", path, classify(path, err))
 	}
 
 	return nil
 }
 
-func (c *Client) GetFileInfo(path string) (*FileInfo, error) {
+// Move moves the file or folder at fromPath to toPath, creating missing
+// parent folders. It fails with ErrConflict if toPath exists.
+func (c *Client) Move(ctx context.Context, fromPath, toPath string) (*FileInfo, error) {
+	fromPath = normalizePath(fromPath)
+	toPath = normalizePath(toPath)
+
+	var result *files.RelocationResult
+	err := c.retry(ctx, func() (err error) {
+		result, err = c.files(ctx).MoveV2(files.NewRelocationArg(fromPath, toPath))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to move '%s' to '%s': %w", fromPath, toPath, classify(toPath, err))
+	}
+
+	return relocated(toPath, result)
+}
+
+// Copy copies the file or folder at fromPath to toPath, creating missing
+// parent folders. It fails with ErrConflict if toPath exists.
+func (c *Client) Copy(ctx context.Context, fromPath, toPath string) (*FileInfo, error) {
+	fromPath = normalizePath(fromPath)
+	toPath = normalizePath(toPath)
+
+	var result *files.RelocationResult
+	err := c.retry(ctx, func() (err error) {
+		result, err = c.files(ctx).CopyV2(files.NewRelocationArg(fromPath, toPath))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy '%s' to '%s': %w", fromPath, toPath, classify(toPath, err))
+	}
+
+	return relocated(toPath, result)
+}
+
+func relocated(toPath string, result *files.RelocationResult) (*FileInfo, error) {
+	info := newFileInfo(result.Metadata)
+	if info == nil {
+		return nil, fmt.Errorf("unknown metadata type for '%s'", toPath)
+	}
+	return info, nil
+}
+
+func (c *Client) GetFileInfo(ctx context.Context, path string) (*FileInfo, error) {
 
 	path = normalizePath(path)
 
 	getMetadataArg := files.NewGetMetadataArg(path)
-	metadata, err := c.filesClient.GetMetadata(getMetadataArg)
+	var metadata files.IsMetadata
+	err := c.retry(ctx, func() (err error) {
+		metadata, err = c.files(ctx).GetMetadata(getMetadataArg)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get metadata for '%s': %w", path, err)
-	}
-
-	switch m := metadata.(type) {
-	case *files.FolderMetadata:
-		return &FileInfo{
-			m.Name,
-			m.PathLower,
-			true,
-			0,
-		}, nil
-	case *files.FileMetadata:
-		return &FileInfo{
-			m.Name,
-			m.PathLower,
-			false,
-			m.Size,
-		}, nil
-	default:
+		return nil, fmt.Errorf("failed to get metadata for '%s': %w", path, classify(path, err))
+	}
+
+	info := newFileInfo(metadata)
+	if info == nil {
 		return nil, fmt.Errorf("unknown metadata type for '%s'", path)
 	}
+
+	return info, nil
 }
 
-func (c *Client) TestConnection() error {
-	_, err := c.GetFileInfo("/")
+func (c *Client) TestConnection(ctx context.Context) error {
+	_, err := c.GetFileInfo(ctx, "/")
 	if err != nil {
 		return fmt.Errorf("connection test failed: %w", err)
 	}
@@ -224,4 +315,17 @@ func normalizePath(path string) string {
 	}
 
 	return path
-}
\ No newline at end of file
+}
+
+// RevokeToken disables the access token on Dropbox, together with the
+// refresh token it was issued for
+func (c *Client) RevokeToken(ctx context.Context) error {
+	err := c.retry(ctx, func() error {
+		return c.auth(ctx).TokenRevoke()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", classify("", err))
+	}
+
+	return nil
+}
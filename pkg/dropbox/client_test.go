@@ -0,0 +1,305 @@
+package dropbox_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"valboks/pkg/dropbox"
+	"valboks/pkg/dropbox/dropboxtest"
+)
+
+// fastRetries retries quickly so that injected faults do not slow tests down
+var fastRetries = dropbox.WithRetryPolicy(dropbox.RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    5 * time.Millisecond,
+})
+
+// newTestClient starts a fake server and returns it with a client for it
+func newTestClient(t *testing.T, serverOpts []dropboxtest.Option, opts ...dropbox.Option) (*dropboxtest.Server, *dropbox.Client) {
+	t.Helper()
+
+	server := dropboxtest.NewServer(serverOpts...)
+	t.Cleanup(server.Close)
+
+	return server, server.NewClient(append([]dropbox.Option{fastRetries}, opts...)...)
+}
+
+func putFile(t *testing.T, server *dropboxtest.Server, p string, content []byte) {
+	t.Helper()
+
+	if err := server.PutFile(p, content); err != nil {
+		t.Fatalf("PutFile(%q): %v", p, err)
+	}
+}
+
+func TestListFolderPages(t *testing.T) {
+	server, client := newTestClient(t, []dropboxtest.Option{dropboxtest.WithPageSize(2)})
+	for i := range 5 {
+		putFile(t, server, fmt.Sprintf("/folder/file%d.txt", i), []byte("data"))
+	}
+	putFile(t, server, "/folder/sub/nested.txt", []byte("nested"))
+
+	entries, err := client.ListFolder(context.Background(), "/folder")
+	if err != nil {
+		t.Fatalf("ListFolder: %v", err)
+	}
+	if len(entries) != 6 {
+		t.Fatalf("ListFolder returned %d entries, want 6", len(entries))
+	}
+	if got := server.Requests("files/list_folder/continue"); got != 2 {
+		t.Errorf("list_folder/continue requests = %d, want 2", got)
+	}
+}
+
+func TestListFolderIterStopsEarly(t *testing.T) {
+	server, client := newTestClient(t, []dropboxtest.Option{dropboxtest.WithPageSize(2)})
+	for i := range 6 {
+		putFile(t, server, fmt.Sprintf("/folder/file%d.txt", i), []byte("data"))
+	}
+
+	n := 0
+	for _, err := range client.ListFolderIter(context.Background(), "/folder", false) {
+		if err != nil {
+			t.Fatalf("ListFolderIter: %v", err)
+		}
+		n++
+		if n == 2 {
+			break
+		}
+	}
+
+	if got := server.Requests("files/list_folder/continue"); got != 0 {
+		t.Errorf("list_folder/continue requests = %d, want 0 after stopping on the first page", got)
+	}
+}
+
+func TestListFolderNotFound(t *testing.T) {
+	_, client := newTestClient(t, nil)
+
+	_, err := client.ListFolder(context.Background(), "/missing")
+	if !errors.Is(err, dropbox.ErrNotFound) {
+		t.Fatalf("ListFolder error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetFileInfo(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	content := []byte("hello world")
+	putFile(t, server, "/Docs/Readme.txt", content)
+
+	info, err := client.GetFileInfo(context.Background(), "/docs/readme.txt")
+	if err != nil {
+		t.Fatalf("GetFileInfo: %v", err)
+	}
+
+	want, _ := dropbox.ContentHash(bytes.NewReader(content))
+	switch {
+	case info.Name != "Readme.txt":
+		t.Errorf("Name = %q, want Readme.txt", info.Name)
+	case info.PathDisplay != "/Docs/Readme.txt":
+		t.Errorf("PathDisplay = %q, want /Docs/Readme.txt", info.PathDisplay)
+	case info.Path != "/docs/readme.txt":
+		t.Errorf("Path = %q, want /docs/readme.txt", info.Path)
+	case info.IsFolder:
+		t.Error("IsFolder = true for a file")
+	case info.Size != uint64(len(content)):
+		t.Errorf("Size = %d, want %d", info.Size, len(content))
+	case info.ContentHash != want:
+		t.Errorf("ContentHash = %q, want %q", info.ContentHash, want)
+	case info.Rev == "":
+		t.Error("Rev is empty")
+	}
+
+	folder, err := client.GetFileInfo(context.Background(), "/docs")
+	if err != nil {
+		t.Fatalf("GetFileInfo folder: %v", err)
+	}
+	if !folder.IsFolder {
+		t.Error("IsFolder = false for a folder")
+	}
+
+	_, err = client.GetFileInfo(context.Background(), "/docs/missing.txt")
+	if !errors.Is(err, dropbox.ErrNotFound) {
+		t.Errorf("GetFileInfo missing error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeletePath(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	putFile(t, server, "/folder/a.txt", []byte("a"))
+	putFile(t, server, "/folder/sub/b.txt", []byte("b"))
+
+	if err := client.DeletePath(context.Background(), "/folder"); err != nil {
+		t.Fatalf("DeletePath: %v", err)
+	}
+	if server.Exists("/folder") || server.Exists("/folder/sub/b.txt") {
+		t.Error("folder or its contents still exist after DeletePath")
+	}
+
+	err := client.DeletePath(context.Background(), "/folder")
+	if !errors.Is(err, dropbox.ErrNotFound) {
+		t.Errorf("second DeletePath error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCreateFolder(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	putFile(t, server, "/file.txt", []byte("x"))
+
+	if err := client.CreateFolder(context.Background(), "/a/b/c"); err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	if !server.Exists("/a/b") || !server.Exists("/a/b/c") {
+		t.Error("CreateFolder did not create the folder and its parents")
+	}
+
+	tests := []struct {
+		path   string
+		reason string
+	}{
+		{"/a/b/c", "folder"},
+		{"/file.txt", "file"},
+	}
+	for _, tt := range tests {
+		err := client.CreateFolder(context.Background(), tt.path)
+		var conflict *dropbox.ConflictError
+		if !errors.As(err, &conflict) || conflict.Reason != tt.reason {
+			t.Errorf("CreateFolder(%q) error = %v, want a %s conflict", tt.path, err, tt.reason)
+		}
+	}
+}
+
+func TestCreateFolderBatch(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		t.Run(fmt.Sprintf("async=%v", async), func(t *testing.T) {
+			var opts []dropboxtest.Option
+			if async {
+				opts = append(opts, dropboxtest.WithAsyncFolderBatches())
+			}
+			server, client := newTestClient(t, opts)
+			if err := server.Mkdir("/exists"); err != nil {
+				t.Fatal(err)
+			}
+
+			errs, err := client.CreateFolderBatch(context.Background(), []string{"/new/nested", "/exists"})
+			if err != nil {
+				t.Fatalf("CreateFolderBatch: %v", err)
+			}
+			if errs[0] != nil {
+				t.Errorf("error for /new/nested = %v, want nil", errs[0])
+			}
+			if !errors.Is(errs[1], dropbox.ErrConflict) {
+				t.Errorf("error for /exists = %v, want ErrConflict", errs[1])
+			}
+			if !server.Exists("/new/nested") {
+				t.Error("/new/nested was not created")
+			}
+		})
+	}
+}
+
+func TestMoveAndCopy(t *testing.T) {
+	tests := []struct {
+		name     string
+		relocate func(c *dropbox.Client, ctx context.Context, from, to string) (*dropbox.FileInfo, error)
+		keepsSrc bool
+	}{
+		{"move", (*dropbox.Client).Move, false},
+		{"copy", (*dropbox.Client).Copy, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestClient(t, nil)
+			putFile(t, server, "/src/a.txt", []byte("a"))
+			putFile(t, server, "/src/sub/b.txt", []byte("b"))
+			putFile(t, server, "/taken.txt", []byte("t"))
+
+			info, err := tt.relocate(client, context.Background(), "/src", "/dst/moved")
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if info.PathDisplay != "/dst/moved" || !info.IsFolder {
+				t.Errorf("result = %+v, want folder /dst/moved", info)
+			}
+
+			content, ok := server.ReadFile("/dst/moved/sub/b.txt")
+			if !ok || string(content) != "b" {
+				t.Errorf("/dst/moved/sub/b.txt = %q, %v, want \"b\"", content, ok)
+			}
+			if server.Exists("/src/a.txt") != tt.keepsSrc {
+				t.Errorf("source exists = %v, want %v", !tt.keepsSrc, tt.keepsSrc)
+			}
+
+			_, err = tt.relocate(client, context.Background(), "/dst/moved/a.txt", "/taken.txt")
+			if !errors.Is(err, dropbox.ErrConflict) {
+				t.Errorf("%s onto an existing file error = %v, want ErrConflict", tt.name, err)
+			}
+
+			_, err = tt.relocate(client, context.Background(), "/missing", "/elsewhere")
+			if !errors.Is(err, dropbox.ErrNotFound) {
+				t.Errorf("%s of a missing path error = %v, want ErrNotFound", tt.name, err)
+			}
+		})
+	}
+}
+
+func TestInjectedFaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		fault    dropboxtest.Fault
+		times    int
+		wantErr  error
+		requests int
+	}{
+		{"rate limited", dropboxtest.RateLimited(0), 1, nil, 2},
+		{"too many write operations", dropboxtest.TooManyWriteOperations(), 1, nil, 2},
+		{"server error", dropboxtest.ServerError(http.StatusServiceUnavailable), 2, nil, 3},
+		{"connection reset", dropboxtest.ConnectionReset(), 1, nil, 2},
+		{"zero fault defaults to 500", dropboxtest.Fault{}, 1, nil, 2},
+		{"no fault for zero times", dropboxtest.ServerError(http.StatusInternalServerError), 0, nil, 1},
+		{"rate limited past max attempts", dropboxtest.RateLimited(0), 3, dropbox.ErrRateLimited, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestClient(t, nil)
+			if err := server.Mkdir("/folder"); err != nil {
+				t.Fatal(err)
+			}
+			server.InjectFault("files/get_metadata", tt.times, tt.fault)
+
+			_, err := client.GetFileInfo(context.Background(), "/folder")
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("GetFileInfo: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetFileInfo error = %v, want %v", err, tt.wantErr)
+			}
+			if got := server.Requests("files/get_metadata"); got != tt.requests {
+				t.Errorf("get_metadata requests = %d, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+func TestInjectedFaultOnlyMatchesRoute(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	if err := server.Mkdir("/folder"); err != nil {
+		t.Fatal(err)
+	}
+	server.InjectFault("files/delete_v2", 5, dropboxtest.ServerError(http.StatusInternalServerError))
+
+	if _, err := client.GetFileInfo(context.Background(), "/folder"); err != nil {
+		t.Fatalf("GetFileInfo: %v", err)
+	}
+	if got := server.Requests("files/get_metadata"); got != 1 {
+		t.Errorf("get_metadata requests = %d, want 1", got)
+	}
+}
@@ -0,0 +1,132 @@
+package dropbox
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+// ContentHashBlockSize is the block size of the Dropbox content hash
+const ContentHashBlockSize = 4 * 1024 * 1024
+
+// contentHash implements the Dropbox content hash: the SHA-256 of the
+// concatenated SHA-256 digests of each 4MB block of the data.
+// See https://www.dropbox.com/developers/reference/content-hash
+type contentHash struct {
+	overall hash.Hash
+	block   hash.Hash
+	// blockLen is the number of bytes written to the current block
+	blockLen int
+}
+
+// NewContentHash returns a hash.Hash computing the Dropbox content hash.
+// The hex encoding of its sum is the value Dropbox reports as ContentHash.
+func NewContentHash() hash.Hash {
+	return &contentHash{
+		overall: sha256.New(),
+		block:   sha256.New(),
+	}
+}
+
+func (h *contentHash) Write(p []byte) (int, error) {
+	written := len(p)
+
+	for len(p) > 0 {
+		n := min(len(p), ContentHashBlockSize-h.blockLen)
+		h.block.Write(p[:n])
+		h.blockLen += n
+		p = p[n:]
+
+		if h.blockLen == ContentHashBlockSize {
+			h.overall.Write(h.block.Sum(nil))
+			h.block.Reset()
+			h.blockLen = 0
+		}
+	}
+
+	return written, nil
+}
+
+func (h *contentHash) Sum(b []byte) []byte {
+	if h.blockLen == 0 {
+		return h.overall.Sum(b)
+	}
+
+	// Fold the unfinished block into a copy so the hash can keep being written to
+	state, err := h.overall.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	overall := sha256.New()
+	err = overall.(encoding.BinaryUnmarshaler).UnmarshalBinary(state)
+	if err != nil {
+		panic(err)
+	}
+
+	overall.Write(h.block.Sum(nil))
+	return overall.Sum(b)
+}
+
+func (h *contentHash) Reset() {
+	h.overall.Reset()
+	h.block.Reset()
+	h.blockLen = 0
+}
+
+func (h *contentHash) Size() int {
+	return sha256.Size
+}
+
+func (h *contentHash) BlockSize() int {
+	return ContentHashBlockSize
+}
+
+// ContentHash computes the hex encoded Dropbox content hash of everything read from r
+func ContentHash(r io.Reader) (string, error) {
+	h := NewContentHash()
+
+	_, err := io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FileContentHash computes the Dropbox content hash of a local file
+func FileContentHash(localPath string) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open local file '%s': %w", localPath, err)
+	}
+	defer file.Close()
+
+	hash, err := ContentHash(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash local file '%s': %w", localPath, err)
+	}
+
+	return hash, nil
+}
+
+// chunkContentHash returns the content hash of an in-memory chunk
+func chunkContentHash(chunk []byte) string {
+	h := NewContentHash()
+	h.Write(chunk)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verifyContentHash compares the content hash Dropbox reports for a file with the local one
+func verifyContentHash(dropboxPath, localHash string, metadata *files.FileMetadata) error {
+	if metadata.ContentHash != localHash {
+		return fmt.Errorf("%w for '%s': local %s, remote %s", ErrHashMismatch, dropboxPath, localHash, metadata.ContentHash)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package dropbox_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"valboks/pkg/dropbox"
+)
+
+func TestContentHash(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"short", []byte("hello world"), "bc62d4b80d9e36da29c16c5d4d9f11731f36052c72401a76c23c0fb5a9b74423"},
+		{"exactly one block", make([]byte, dropbox.ContentHashBlockSize), "c7e946d101855255d919ef0c70718633adf77d3dfb3adeeecf5d0cb4e951be58"},
+		{"one byte over a block", make([]byte, dropbox.ContentHashBlockSize+1), "14a4d47f23a30177885d9820122f17d2d3a55fe63f7f5c27b95f689e0b2accd6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dropbox.ContentHash(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("ContentHash: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ContentHash = %s, want %s", got, tt.want)
+			}
+
+			// Writes that straddle block boundaries must give the same hash
+			h := dropbox.NewContentHash()
+			for data := tt.data; len(data) > 0; {
+				n := min(len(data), 1000003)
+				h.Write(data[:n])
+				data = data[n:]
+			}
+			if got := hex.EncodeToString(h.Sum(nil)); got != tt.want {
+				t.Errorf("hash of odd sized writes = %s, want %s", got, tt.want)
+			}
+
+			localPath := filepath.Join(t.TempDir(), "file")
+			if err := os.WriteFile(localPath, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err = dropbox.FileContentHash(localPath)
+			if err != nil {
+				t.Fatalf("FileContentHash: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FileContentHash = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
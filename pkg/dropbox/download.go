@@ -0,0 +1,401 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+const (
+	// PartialSuffix is appended to the local path while a download is in progress
+	PartialSuffix = ".part"
+
+	// DefaultRangeSize is the size of each range of a parallel download when none is configured
+	DefaultRangeSize = 32 * 1024 * 1024
+)
+
+// DownloadOptions controls how DownloadFile transfers a file
+type DownloadOptions struct {
+	// Concurrency is the number of byte ranges fetched at the same time.
+	// Values below 2 download the file as a single stream.
+	Concurrency int
+	// RangeSize is the number of bytes requested per range, files no larger
+	// than this are always downloaded as a single stream
+	RangeSize int64
+}
+
+func (o DownloadOptions) rangeSize() int64 {
+	if o.RangeSize <= 0 {
+		return DefaultRangeSize
+	}
+	return o.RangeSize
+}
+
+// DownloadFile downloads a file from Dropbox to localPath. The data is
+// written to a sibling file named localPath+PartialSuffix, which is renamed
+// into place only after its size and content hash match the remote file.
+// If a partial file is left over from an interrupted download, only the
+// missing bytes are requested. Otherwise large files are split into ranges
+// that are fetched in parallel when opts.Concurrency allows it. When ctx is
+// cancelled the transfer stops and the partial file is kept for resuming.
+func (c *Client) DownloadFile(ctx context.Context, dropboxPath, localPath string, opts DownloadOptions) error {
+
+	dropboxPath = normalizePath(dropboxPath)
+	partPath := localPath + PartialSuffix
+
+	if _, err := os.Stat(partPath); opts.Concurrency > 1 && os.IsNotExist(err) {
+		parallel, err := c.downloadRanges(ctx, dropboxPath, partPath, opts)
+		if err != nil {
+			return err
+		}
+		if parallel {
+			return renamePartial(partPath, localPath)
+		}
+	}
+
+	resumed, err := c.downloadPartial(ctx, dropboxPath, partPath)
+	if resumed && errors.Is(err, ErrHashMismatch) {
+		// The remote file may have changed since the partial download was
+		// started. downloadPartial already removed the partial data, so
+		// fetch it all again.
+		_, err = c.downloadPartial(ctx, dropboxPath, partPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	return renamePartial(partPath, localPath)
+}
+
+func renamePartial(partPath, localPath string) error {
+	err := os.Rename(partPath, localPath)
+	if err != nil {
+		return fmt.Errorf("failed to move '%s' into place: %w", partPath, err)
+	}
+
+	return nil
+}
+
+// downloadRanges fetches the file in opts.RangeSize pieces using up to
+// opts.Concurrency parallel requests, writing each piece at its offset in
+// a preallocated partial file. Every range is read from the same revision.
+// It reports false without downloading anything if the file is too small to
+// be split. Holes left by a failed parallel download cannot be resumed, so
+// the partial file is removed on error.
+func (c *Client) downloadRanges(ctx context.Context, dropboxPath, partPath string, opts DownloadOptions) (bool, error) {
+	var metadata files.IsMetadata
+	err := c.retry(ctx, func() (err error) {
+		metadata, err = c.files(ctx).GetMetadata(files.NewGetMetadataArg(dropboxPath))
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get metadata for '%s': %w", dropboxPath, classify(dropboxPath, err))
+	}
+
+	fileMetadata, ok := metadata.(*files.FileMetadata)
+	if !ok {
+		return false, fmt.Errorf("'%s' is not a file", dropboxPath)
+	}
+
+	rangeSize := opts.rangeSize()
+	size := int64(fileMetadata.Size)
+	if size <= rangeSize {
+		return false, nil
+	}
+
+	err = c.fetchRanges(ctx, "rev:"+fileMetadata.Rev, partPath, size, rangeSize, opts.Concurrency)
+	if err == nil {
+		err = verifyDownload(partPath, uint64(size), fileMetadata)
+	}
+	if err != nil {
+		os.Remove(partPath)
+		return false, fmt.Errorf("failed to download file '%s': %w", dropboxPath, classify(dropboxPath, err))
+	}
+
+	return true, nil
+}
+
+// fetchRanges downloads size bytes of source into a preallocated file at
+// partPath, rangeSize bytes per request and up to concurrency requests at once.
+func (c *Client) fetchRanges(ctx context.Context, source, partPath string, size, rangeSize int64, concurrency int) error {
+	partFile, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create local file '%s': %w", partPath, err)
+	}
+	defer partFile.Close()
+
+	err = partFile.Truncate(size)
+	if err != nil {
+		return fmt.Errorf("failed to preallocate local file '%s': %w", partPath, err)
+	}
+
+	starts := make(chan int64)
+	errs := make(chan error, concurrency)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range starts {
+				err := c.fetchRange(ctx, source, partFile, start, min(rangeSize, size-start))
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(starts)
+		for start := int64(0); start < size; start += rangeSize {
+			select {
+			case starts <- start:
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+
+	// Stop handing out ranges after the first failure, the remaining
+	// workers finish the range they are on
+	err = <-errs
+	close(done)
+	for range errs {
+		// Drain the errors of workers that failed after the first one
+	}
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return partFile.Close()
+}
+
+// fetchRange downloads length bytes of source starting at start into file
+func (c *Client) fetchRange(ctx context.Context, source string, file *os.File, start, length int64) error {
+	downloadArg := files.NewDownloadArg(source)
+	downloadArg.ExtraHeaders = map[string]string{"Range": fmt.Sprintf("bytes=%d-%d", start, start+length-1)}
+
+	var content io.ReadCloser
+	err := c.retry(ctx, func() (err error) {
+		_, content, err = c.files(ctx).Download(downloadArg)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download bytes %d-%d: %w", start, start+length-1, err)
+	}
+	defer content.Close()
+
+	written, err := io.Copy(io.NewOffsetWriter(file, start), io.LimitReader(content, length))
+	if err != nil {
+		return fmt.Errorf("failed to write bytes %d-%d: %w", start, start+length-1, err)
+	}
+	if written != length {
+		return fmt.Errorf("%w: received %d of %d bytes at offset %d", ErrHashMismatch, written, length, start)
+	}
+
+	return nil
+}
+
+// downloadPartial completes the partial file at partPath and verifies it
+// against the remote metadata. It reports whether an existing partial file
+// was resumed. A partial file that fails verification is removed.
+func (c *Client) downloadPartial(ctx context.Context, dropboxPath, partPath string) (bool, error) {
+	partFile, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return false, fmt.Errorf("failed to create local file '%s': %w", partPath, err)
+	}
+	defer partFile.Close()
+
+	offset, err := partFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, fmt.Errorf("failed to read partial file '%s': %w", partPath, err)
+	}
+
+	downloadArg := files.NewDownloadArg(dropboxPath)
+	if offset > 0 {
+		downloadArg.ExtraHeaders = map[string]string{"Range": fmt.Sprintf("bytes=%d-", offset)}
+	}
+
+	filesClient := c.files(ctx)
+	var metadata *files.FileMetadata
+	var content io.ReadCloser
+	err = c.retry(ctx, func() (err error) {
+		metadata, content, err = filesClient.Download(downloadArg)
+		return err
+	})
+	if offset > 0 && isRangeNotSatisfiable(err) {
+		// The partial file is at least as long as the remote file, start over
+		err = partFile.Truncate(0)
+		if err != nil {
+			return false, fmt.Errorf("failed to truncate partial file '%s': %w", partPath, err)
+		}
+		offset = 0
+
+		_, err = partFile.Seek(0, io.SeekStart)
+		if err != nil {
+			return false, fmt.Errorf("failed to read partial file '%s': %w", partPath, err)
+		}
+
+		err = c.retry(ctx, func() (err error) {
+			metadata, content, err = filesClient.Download(files.NewDownloadArg(dropboxPath))
+			return err
+		})
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to download file '%s': %w", dropboxPath, classify(dropboxPath, err))
+	}
+	defer content.Close()
+
+	//Copy content to file
+	written, err := io.Copy(partFile, content)
+	if err != nil {
+		return offset > 0, fmt.Errorf("failed to write file content: '%w'", err)
+	}
+
+	err = partFile.Close()
+	if err != nil {
+		return offset > 0, fmt.Errorf("failed to write file content: '%w'", err)
+	}
+
+	err = verifyDownload(partPath, uint64(offset+written), metadata)
+	if err != nil {
+		os.Remove(partPath)
+		return offset > 0, fmt.Errorf("failed to download file '%s': %w", dropboxPath, err)
+	}
+
+	return offset > 0, nil
+}
+
+// OpenFile starts downloading the file at dropboxPath and returns its
+// metadata and content. The content must be closed, reading it stops when
+// ctx is done. Unlike DownloadFile the content is not verified.
+func (c *Client) OpenFile(ctx context.Context, dropboxPath string) (*FileInfo, io.ReadCloser, error) {
+	dropboxPath = normalizePath(dropboxPath)
+
+	var metadata *files.FileMetadata
+	var content io.ReadCloser
+	err := c.retry(ctx, func() (err error) {
+		metadata, content, err = c.files(ctx).Download(files.NewDownloadArg(dropboxPath))
+		return err
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to download file '%s': %w", dropboxPath, classify(dropboxPath, err))
+	}
+
+	return newFileInfo(metadata), content, nil
+}
+
+// verifyDownload checks the downloaded file against the remote size and content hash
+func verifyDownload(localPath string, size uint64, metadata *files.FileMetadata) error {
+	if size != metadata.Size {
+		return fmt.Errorf("%w: received %d of %d bytes", ErrHashMismatch, size, metadata.Size)
+	}
+
+	hash, err := FileContentHash(localPath)
+	if err != nil {
+		return err
+	}
+
+	return verifyContentHash(metadata.PathDisplay, hash, metadata)
+}
+
+// isRangeNotSatisfiable reports whether a ranged download started past the end of the file
+func isRangeNotSatisfiable(err error) bool {
+	var sdkErr dropbox.SDKInternalError
+	return errors.As(err, &sdkErr) && sdkErr.StatusCode == http.StatusRequestedRangeNotSatisfiable
+}
+
+// IsLocalCopy reports whether the file at localPath has the same size and
+// content hash as the remote file described by info.
+func IsLocalCopy(localPath string, info *FileInfo) (bool, error) {
+	stat, err := os.Stat(localPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get the file info: %w", err)
+	}
+
+	if !stat.Mode().IsRegular() || uint64(stat.Size()) != info.Size || info.ContentHash == "" {
+		return false, nil
+	}
+
+	hash, err := FileContentHash(localPath)
+	if err != nil {
+		return false, err
+	}
+
+	return hash == info.ContentHash, nil
+}
+
+// DownloadFolder mirrors the folder at dropboxPath into localDir, creating
+// local directories as needed. Files that already exist locally with the
+// same size and content hash are skipped, the rest are downloaded with
+// DownloadFile using opts. If progress is not nil it is
+// called after every file with the local path and whether it was skipped.
+func (c *Client) DownloadFolder(ctx context.Context, dropboxPath, localDir string, opts DownloadOptions, progress func(localPath string, skipped bool)) error {
+	err := os.MkdirAll(localDir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create local directory '%s': %w", localDir, err)
+	}
+
+	entries, err := c.ListFolder(ctx, dropboxPath)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		localPath := filepath.Join(localDir, entry.Name)
+
+		if entry.IsFolder {
+			err = c.DownloadFolder(ctx, entry.Path, localPath, opts, progress)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		skipped, err := IsLocalCopy(localPath, &entry)
+		if err != nil {
+			return err
+		}
+
+		if !skipped {
+			err = c.DownloadFile(ctx, entry.Path, localPath, opts)
+			if err != nil {
+				return err
+			}
+		}
+
+		if progress != nil {
+			progress(localPath, skipped)
+		}
+	}
+
+	return nil
+}
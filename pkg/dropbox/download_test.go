@@ -0,0 +1,135 @@
+package dropbox_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"valboks/pkg/dropbox"
+	"valboks/pkg/dropbox/dropboxtest"
+)
+
+func TestDownloadResumesPartialFile(t *testing.T) {
+	content := []byte("the quick brown fox jumps over the lazy dog")
+
+	tests := []struct {
+		name      string
+		partial   string
+		downloads int
+	}{
+		{"no partial file", "", 1},
+		{"matching prefix", "the quick brown", 1},
+		{"stale prefix", "THE QUICK BROWN", 2},
+		{"partial file as long as the remote file", string(content), 2},
+		{"partial file longer than the remote file", string(content) + " and more", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestClient(t, nil)
+			putFile(t, server, "/file.txt", content)
+
+			localPath := filepath.Join(t.TempDir(), "file.txt")
+			if tt.partial != "" {
+				err := os.WriteFile(localPath+dropbox.PartialSuffix, []byte(tt.partial), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := client.DownloadFile(context.Background(), "/file.txt", localPath, dropbox.DownloadOptions{})
+			if err != nil {
+				t.Fatalf("DownloadFile: %v", err)
+			}
+
+			got, err := os.ReadFile(localPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(content) {
+				t.Errorf("downloaded content = %q, want %q", got, content)
+			}
+			if _, err := os.Stat(localPath + dropbox.PartialSuffix); !os.IsNotExist(err) {
+				t.Errorf("partial file still exists after the download: %v", err)
+			}
+			if got := server.Requests("files/download"); got != tt.downloads {
+				t.Errorf("download requests = %d, want %d", got, tt.downloads)
+			}
+		})
+	}
+}
+
+func TestDownloadMissingFile(t *testing.T) {
+	_, client := newTestClient(t, nil)
+	localPath := filepath.Join(t.TempDir(), "missing.txt")
+
+	err := client.DownloadFile(context.Background(), "/missing.txt", localPath, dropbox.DownloadOptions{})
+	if !errors.Is(err, dropbox.ErrNotFound) {
+		t.Fatalf("DownloadFile error = %v, want ErrNotFound", err)
+	}
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+		t.Errorf("local file exists after a failed download: %v", err)
+	}
+}
+
+func TestDownloadParallelRanges(t *testing.T) {
+	content := make([]byte, 100)
+	for i := range content {
+		content[i] = byte(i)
+	}
+
+	tests := []struct {
+		name      string
+		opts      dropbox.DownloadOptions
+		downloads int
+	}{
+		{"single stream without concurrency", dropbox.DownloadOptions{Concurrency: 1, RangeSize: 16}, 1},
+		{"file fits in one range", dropbox.DownloadOptions{Concurrency: 4, RangeSize: 100}, 1},
+		{"uneven last range", dropbox.DownloadOptions{Concurrency: 4, RangeSize: 16}, 7},
+		{"more workers than ranges", dropbox.DownloadOptions{Concurrency: 8, RangeSize: 40}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestClient(t, nil)
+			putFile(t, server, "/big.bin", content)
+			localPath := filepath.Join(t.TempDir(), "big.bin")
+
+			if err := client.DownloadFile(context.Background(), "/big.bin", localPath, tt.opts); err != nil {
+				t.Fatalf("DownloadFile: %v", err)
+			}
+
+			got, err := os.ReadFile(localPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("reassembled content = %v, want %v", got, content)
+			}
+			if got := server.Requests("files/download"); got != tt.downloads {
+				t.Errorf("download requests = %d, want %d", got, tt.downloads)
+			}
+		})
+	}
+}
+
+func TestDownloadParallelRangesFailure(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	putFile(t, server, "/big.bin", make([]byte, 100))
+	server.InjectFault("files/download", 100, dropboxtest.ServerError(http.StatusInternalServerError))
+	localPath := filepath.Join(t.TempDir(), "big.bin")
+
+	err := client.DownloadFile(context.Background(), "/big.bin", localPath, dropbox.DownloadOptions{Concurrency: 4, RangeSize: 16})
+	if err == nil {
+		t.Fatal("DownloadFile succeeded despite the injected faults")
+	}
+	for _, p := range []string{localPath, localPath + dropbox.PartialSuffix} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s exists after a failed parallel download: %v", p, err)
+		}
+	}
+}
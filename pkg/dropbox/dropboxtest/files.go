@@ -0,0 +1,720 @@
+package dropboxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk "github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+)
+
+// route handles requests to one API route. style is rpc, upload or
+// download and decides whether the argument is sent in the body or in the
+// Dropbox-API-Arg header.
+type route struct {
+	style string
+	serve func(w http.ResponseWriter, r *http.Request, arg, body []byte)
+}
+
+// handlerFunc handles an rpc or upload request, returning the result to
+// encode as JSON or an error response
+type handlerFunc func(arg, body []byte) (any, *apiError)
+
+func rpc(handle handlerFunc) route {
+	return route{style: "rpc", serve: serveJSON(handle)}
+}
+
+func upload(handle handlerFunc) route {
+	return route{style: "upload", serve: serveJSON(handle)}
+}
+
+func serveJSON(handle handlerFunc) func(w http.ResponseWriter, r *http.Request, arg, body []byte) {
+	return func(w http.ResponseWriter, r *http.Request, arg, body []byte) {
+		result, err := handle(arg, body)
+		if err != nil {
+			err.write(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func (s *Server) routes() map[string]route {
+	return map[string]route{
+		"files/list_folder":                    rpc(s.listFolder),
+		"files/list_folder/continue":           rpc(s.listFolderContinue),
+		"files/get_metadata":                   rpc(s.getMetadata),
+		"files/delete_v2":                      rpc(s.delete),
+		"files/create_folder_v2":               rpc(s.createFolder),
+		"files/create_folder_batch":            rpc(s.createFolderBatch),
+		"files/create_folder_batch/check":      rpc(s.createFolderBatchCheck),
+		"files/move_v2":                        rpc(s.relocate(true)),
+		"files/copy_v2":                        rpc(s.relocate(false)),
+		"files/list_revisions":                 rpc(s.listRevisions),
+		"files/restore":                        rpc(s.restore),
+		"files/upload":                         upload(s.upload),
+		"files/upload_session/start":           upload(s.uploadSessionStart),
+		"files/upload_session/append_v2":       upload(s.uploadSessionAppend),
+		"files/upload_session/finish":          upload(s.uploadSessionFinish),
+		"files/upload_session/finish_batch_v2": rpc(s.uploadSessionFinishBatch),
+		"files/download":                       {style: "download", serve: s.download},
+	}
+}
+
+// decode parses the argument of a request to route into v
+func decode(route string, arg []byte, v any) *apiError {
+	err := json.Unmarshal(arg, v)
+	if err != nil {
+		return badRequest(route, "could not decode input as JSON: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) listFolder(arg, _ []byte) (any, *apiError) {
+	var req struct {
+		Path      string `json:"path"`
+		Recursive bool   `json:"recursive"`
+		Limit     int    `json:"limit"`
+	}
+	if err := decode("files/list_folder", arg, &req); err != nil {
+		return nil, err
+	}
+
+	n, _, err := s.tree.resolve(req.Path)
+	if err != nil {
+		return nil, tagged("path", err)
+	}
+	if !n.folder {
+		return nil, tagged("path", lookupError("not_folder"))
+	}
+
+	var entries []any
+	if req.Recursive && n.display != "" {
+		// Dropbox includes the listed folder itself in recursive listings
+		entries = append(entries, metadata(n))
+	}
+	for _, child := range s.tree.children(strings.ToLower(n.display), req.Recursive) {
+		entries = append(entries, metadata(child))
+	}
+
+	pageSize := s.pageSize
+	if req.Limit > 0 && req.Limit < pageSize {
+		pageSize = req.Limit
+	}
+	return s.page(entries, pageSize), nil
+}
+
+func (s *Server) listFolderContinue(arg, _ []byte) (any, *apiError) {
+	var req struct {
+		Cursor string `json:"cursor"`
+	}
+	if err := decode("files/list_folder/continue", arg, &req); err != nil {
+		return nil, err
+	}
+
+	entries, ok := s.cursors[req.Cursor]
+	if !ok {
+		return nil, tagged("reset", nil)
+	}
+	delete(s.cursors, req.Cursor)
+
+	return s.page(entries, s.pageSize), nil
+}
+
+// page returns the first pageSize entries as a files.ListFolderResult,
+// keeping the rest for list_folder/continue
+func (s *Server) page(entries []any, pageSize int) map[string]any {
+	s.nextID++
+	cursor := fmt.Sprintf("cursor-%d", s.nextID)
+
+	hasMore := len(entries) > pageSize
+	if hasMore {
+		s.cursors[cursor] = entries[pageSize:]
+		entries = entries[:pageSize]
+	}
+	if entries == nil {
+		entries = []any{}
+	}
+
+	return map[string]any{"entries": entries, "cursor": cursor, "has_more": hasMore}
+}
+
+func (s *Server) getMetadata(arg, _ []byte) (any, *apiError) {
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := decode("files/get_metadata", arg, &req); err != nil {
+		return nil, err
+	}
+	if req.Path == "" {
+		return nil, badRequest("files/get_metadata", "request body: path: The root folder is unsupported.")
+	}
+
+	n, rev, err := s.tree.resolve(req.Path)
+	if err != nil {
+		return nil, tagged("path", err)
+	}
+	if rev != nil {
+		return fileMetadata(n, rev), nil
+	}
+	return metadata(n), nil
+}
+
+func (s *Server) delete(arg, _ []byte) (any, *apiError) {
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := decode("files/delete_v2", arg, &req); err != nil {
+		return nil, err
+	}
+
+	n, _, err := s.tree.resolve(req.Path)
+	if err != nil {
+		return nil, tagged("path_lookup", err)
+	}
+	if n.display == "" {
+		return nil, tagged("path_lookup", lookupError("malformed_path"))
+	}
+
+	s.tree.remove(strings.ToLower(n.display))
+	return map[string]any{"metadata": metadata(n)}, nil
+}
+
+func (s *Server) createFolder(arg, _ []byte) (any, *apiError) {
+	var req struct {
+		Path       string `json:"path"`
+		Autorename bool   `json:"autorename"`
+	}
+	if err := decode("files/create_folder_v2", arg, &req); err != nil {
+		return nil, err
+	}
+
+	n, err := s.mkdir(req.Path, req.Autorename)
+	if err != nil {
+		return nil, tagged("path", err)
+	}
+	return map[string]any{"metadata": metadata(n)}, nil
+}
+
+// mkdir creates the folder p and its missing parents, returning a WriteError on failure
+func (s *Server) mkdir(p string, autorename bool) (*node, *apiError) {
+	lower, err := checkPath(p)
+	if err != nil {
+		return nil, err
+	}
+	if lower == "" {
+		return nil, lookupError("malformed_path")
+	}
+
+	display := s.tree.parentDisplay(p) + "/" + path.Base(p)
+	if err := s.tree.checkAncestors(display); err != nil {
+		return nil, err
+	}
+	if existing, ok := s.tree.nodes[lower]; ok {
+		free, ok := s.tree.free(display, autorename)
+		if !ok {
+			conflict := "file"
+			if existing.folder {
+				conflict = "folder"
+			}
+			return nil, conflictError(conflict)
+		}
+		display = free
+	}
+
+	s.tree.mkdirAll(display)
+	n := &node{display: display, folder: true}
+	s.tree.add(n)
+	return n, nil
+}
+
+func (s *Server) createFolderBatch(arg, _ []byte) (any, *apiError) {
+	var req struct {
+		Paths      []string `json:"paths"`
+		Autorename bool     `json:"autorename"`
+		ForceAsync bool     `json:"force_async"`
+	}
+	if err := decode("files/create_folder_batch", arg, &req); err != nil {
+		return nil, err
+	}
+
+	entries := make([]any, len(req.Paths))
+	for i, p := range req.Paths {
+		n, err := s.mkdir(p, req.Autorename)
+		if err != nil {
+			entries[i] = map[string]any{".tag": "failure", "failure": tagged("path", err).body}
+			continue
+		}
+		entries[i] = map[string]any{".tag": "success", "metadata": metadata(n)}
+	}
+
+	if !req.ForceAsync && !s.asyncBatches {
+		return map[string]any{".tag": "complete", "entries": entries}, nil
+	}
+
+	s.nextID++
+	jobID := fmt.Sprintf("job-%d", s.nextID)
+	s.jobs[jobID] = &batchJob{entries: entries, pending: 1}
+	return map[string]any{".tag": "async_job_id", "async_job_id": jobID}, nil
+}
+
+func (s *Server) createFolderBatchCheck(arg, _ []byte) (any, *apiError) {
+	var req struct {
+		AsyncJobID string `json:"async_job_id"`
+	}
+	if err := decode("files/create_folder_batch/check", arg, &req); err != nil {
+		return nil, err
+	}
+
+	job, ok := s.jobs[req.AsyncJobID]
+	if !ok {
+		return nil, tagged("invalid_async_job_id", nil)
+	}
+	if job.pending > 0 {
+		job.pending--
+		return map[string]any{".tag": "in_progress"}, nil
+	}
+
+	delete(s.jobs, req.AsyncJobID)
+	return map[string]any{".tag": "complete", "entries": job.entries}, nil
+}
+
+// relocate handles move_v2 if move is set and copy_v2 otherwise
+func (s *Server) relocate(move bool) handlerFunc {
+	route := "files/copy_v2"
+	if move {
+		route = "files/move_v2"
+	}
+
+	return func(arg, _ []byte) (any, *apiError) {
+		var req struct {
+			FromPath   string `json:"from_path"`
+			ToPath     string `json:"to_path"`
+			Autorename bool   `json:"autorename"`
+		}
+		if err := decode(route, arg, &req); err != nil {
+			return nil, err
+		}
+
+		from, _, err := s.tree.resolve(req.FromPath)
+		if err != nil {
+			return nil, tagged("from_lookup", err)
+		}
+		if from.display == "" {
+			return nil, tagged("from_lookup", lookupError("malformed_path"))
+		}
+		fromLower := strings.ToLower(from.display)
+
+		toLower, err := checkPath(req.ToPath)
+		if err != nil {
+			return nil, tagged("to", err)
+		}
+		if toLower == "" {
+			return nil, tagged("to", lookupError("malformed_path"))
+		}
+		if strings.HasPrefix(toLower, fromLower+"/") {
+			return nil, tagged("cant_move_folder_into_itself", nil)
+		}
+
+		display := s.tree.parentDisplay(req.ToPath) + "/" + path.Base(req.ToPath)
+		if err := s.tree.checkAncestors(display); err != nil {
+			return nil, tagged("to", err)
+		}
+		// Moving to the same path with different casing renames in place
+		if !move || toLower != fromLower {
+			if existing, ok := s.tree.nodes[toLower]; ok {
+				free, ok := s.tree.free(display, req.Autorename)
+				if !ok {
+					conflict := "file"
+					if existing.folder {
+						conflict = "folder"
+					}
+					return nil, tagged("to", conflictError(conflict))
+				}
+				display = free
+			}
+		}
+
+		s.tree.mkdirAll(display)
+		moved := s.relocateTree(from, display, move)
+		return map[string]any{"metadata": metadata(moved)}, nil
+	}
+}
+
+// relocateTree moves or copies from and everything below it to display and
+// returns the node at the new location. Moved files keep their ids and
+// revisions, copies get new ones.
+func (s *Server) relocateTree(from *node, display string, move bool) *node {
+	fromLower := strings.ToLower(from.display)
+	sources := []*node{from}
+	if from.folder {
+		sources = append(sources, s.tree.children(fromLower, true)...)
+	}
+	if move {
+		s.tree.remove(fromLower)
+	}
+
+	var root *node
+	for _, src := range sources {
+		target := &node{display: display + src.display[len(from.display):], folder: src.folder}
+		srcLower := strings.ToLower(src.display)
+		targetLower := strings.ToLower(target.display)
+
+		switch {
+		case move:
+			target.id = src.id
+			target.current = src.current
+			if srcLower != targetLower {
+				s.tree.history[targetLower] = append(s.tree.history[targetLower], s.tree.history[srcLower]...)
+				delete(s.tree.history, srcLower)
+			}
+		case !src.folder:
+			rev := *src.current
+			rev.rev = fmt.Sprintf("%015x", s.tree.counter())
+			rev.serverModified = time.Now().UTC().Truncate(time.Second)
+			target.current = &rev
+			s.tree.history[targetLower] = append(s.tree.history[targetLower], &rev)
+		}
+
+		s.tree.add(target)
+		if root == nil {
+			root = target
+		}
+	}
+
+	return root
+}
+
+func (s *Server) listRevisions(arg, _ []byte) (any, *apiError) {
+	var req struct {
+		Path  string `json:"path"`
+		Limit int    `json:"limit"`
+	}
+	if err := decode("files/list_revisions", arg, &req); err != nil {
+		return nil, err
+	}
+
+	lower, err := checkPath(req.Path)
+	if err != nil {
+		return nil, tagged("path", err)
+	}
+
+	n, exists := s.tree.nodes[lower]
+	if exists && n.folder {
+		return nil, tagged("path", lookupError("not_file"))
+	}
+	revs := s.tree.history[lower]
+	if len(revs) == 0 {
+		return nil, tagged("path", lookupError("not_found"))
+	}
+	if !exists {
+		// Describe revisions of a deleted file with the path they were last stored at
+		n = &node{display: req.Path}
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	entries := []any{}
+	for i := len(revs) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, fileMetadata(n, revs[i]))
+	}
+
+	return map[string]any{"is_deleted": !exists, "entries": entries}, nil
+}
+
+func (s *Server) restore(arg, _ []byte) (any, *apiError) {
+	var req struct {
+		Path string `json:"path"`
+		Rev  string `json:"rev"`
+	}
+	if err := decode("files/restore", arg, &req); err != nil {
+		return nil, err
+	}
+
+	lower, err := checkPath(req.Path)
+	if err != nil {
+		return nil, tagged("path_lookup", err)
+	}
+
+	var restored *revision
+	for _, rev := range s.tree.history[lower] {
+		if rev.rev == req.Rev {
+			restored = rev
+		}
+	}
+	if restored == nil {
+		return nil, tagged("invalid_revision", nil)
+	}
+
+	info := commitInfo{Path: req.Path, Mode: &writeMode{Tag: "overwrite"}, ClientModified: &restored.clientModified}
+	n, err := s.tree.commit(info, restored.content)
+	if err != nil {
+		return nil, tagged("path_write", err)
+	}
+	return metadata(n), nil
+}
+
+func (s *Server) upload(arg, body []byte) (any, *apiError) {
+	var req struct {
+		commitInfo
+		ContentHash string `json:"content_hash"`
+	}
+	if err := decode("files/upload", arg, &req); err != nil {
+		return nil, err
+	}
+	if req.ContentHash != "" && req.ContentHash != contentHash(body) {
+		return nil, tagged("content_hash_mismatch", nil)
+	}
+
+	n, err := s.tree.commit(req.commitInfo, body)
+	if err != nil {
+		// UploadWriteFailed is not nested under the tag like other errors
+		return nil, &apiError{
+			status:  http.StatusConflict,
+			summary: "path/" + err.summary,
+			body:    map[string]any{".tag": "path", "reason": err.body, "upload_session_id": ""},
+		}
+	}
+	return metadata(n), nil
+}
+
+// batchJob is an asynchronous create_folder_batch job
+type batchJob struct {
+	entries []any
+	// pending is the number of checks answered with in_progress before the job completes
+	pending int
+}
+
+// uploadSession holds the data appended to an upload session so far
+type uploadSession struct {
+	data   []byte
+	closed bool
+}
+
+// sessionCursor is the decoded files.UploadSessionCursor
+type sessionCursor struct {
+	SessionID string `json:"session_id"`
+	Offset    int    `json:"offset"`
+}
+
+func (s *Server) uploadSessionStart(arg, body []byte) (any, *apiError) {
+	var req struct {
+		Close       bool   `json:"close"`
+		ContentHash string `json:"content_hash"`
+	}
+	if err := decode("files/upload_session/start", arg, &req); err != nil {
+		return nil, err
+	}
+	if req.ContentHash != "" && req.ContentHash != contentHash(body) {
+		return nil, tagged("content_hash_mismatch", nil)
+	}
+
+	s.nextID++
+	id := fmt.Sprintf("session-%d", s.nextID)
+	s.sessions[id] = &uploadSession{data: bytes.Clone(body), closed: req.Close}
+
+	return map[string]any{"session_id": id}, nil
+}
+
+// appendSession adds body to the session at cursor, returning an
+// UploadSessionLookupError if the session cannot take it
+func (s *Server) appendSession(cursor sessionCursor, body []byte) (*uploadSession, *apiError) {
+	session, ok := s.sessions[cursor.SessionID]
+	if !ok {
+		return nil, lookupError("not_found")
+	}
+	if cursor.Offset != len(session.data) {
+		return nil, &apiError{
+			summary: "incorrect_offset/",
+			body:    map[string]any{".tag": "incorrect_offset", "correct_offset": len(session.data)},
+		}
+	}
+	if session.closed && len(body) > 0 {
+		return nil, lookupError("closed")
+	}
+
+	session.data = append(session.data, body...)
+	return session, nil
+}
+
+func (s *Server) uploadSessionAppend(arg, body []byte) (any, *apiError) {
+	var req struct {
+		Cursor      sessionCursor `json:"cursor"`
+		Close       bool          `json:"close"`
+		ContentHash string        `json:"content_hash"`
+	}
+	if err := decode("files/upload_session/append_v2", arg, &req); err != nil {
+		return nil, err
+	}
+	if req.ContentHash != "" && req.ContentHash != contentHash(body) {
+		return nil, tagged("content_hash_mismatch", nil)
+	}
+
+	session, err := s.appendSession(req.Cursor, body)
+	if err != nil {
+		// UploadSessionAppendError reuses the lookup error tags directly
+		err.status = http.StatusConflict
+		return nil, err
+	}
+	session.closed = session.closed || req.Close
+
+	return nil, nil
+}
+
+// finishArg is the decoded files.UploadSessionFinishArg
+type finishArg struct {
+	Cursor      sessionCursor `json:"cursor"`
+	Commit      commitInfo    `json:"commit"`
+	ContentHash string        `json:"content_hash"`
+}
+
+// finishSession appends body to the session and commits it, returning an
+// UploadSessionFinishError on failure
+func (s *Server) finishSession(req finishArg, body []byte, batch bool) (*node, *apiError) {
+	if req.ContentHash != "" && req.ContentHash != contentHash(body) {
+		return nil, tagged("content_hash_mismatch", nil)
+	}
+
+	if batch {
+		if session, ok := s.sessions[req.Cursor.SessionID]; ok && !session.closed {
+			return nil, tagged("lookup_failed", lookupError("not_closed"))
+		}
+	}
+
+	session, err := s.appendSession(req.Cursor, body)
+	if err != nil {
+		return nil, tagged("lookup_failed", err)
+	}
+
+	n, err := s.tree.commit(req.Commit, session.data)
+	if err != nil {
+		return nil, tagged("path", err)
+	}
+
+	delete(s.sessions, req.Cursor.SessionID)
+	return n, nil
+}
+
+func (s *Server) uploadSessionFinish(arg, body []byte) (any, *apiError) {
+	var req finishArg
+	if err := decode("files/upload_session/finish", arg, &req); err != nil {
+		return nil, err
+	}
+
+	n, err := s.finishSession(req, body, false)
+	if err != nil {
+		return nil, err
+	}
+	return metadata(n), nil
+}
+
+func (s *Server) uploadSessionFinishBatch(arg, _ []byte) (any, *apiError) {
+	var req struct {
+		Entries []finishArg `json:"entries"`
+	}
+	if err := decode("files/upload_session/finish_batch_v2", arg, &req); err != nil {
+		return nil, err
+	}
+
+	entries := make([]any, len(req.Entries))
+	for i, entry := range req.Entries {
+		n, err := s.finishSession(entry, nil, true)
+		if err != nil {
+			entries[i] = map[string]any{".tag": "failure", "failure": err.body}
+			continue
+		}
+
+		result := metadata(n)
+		result[".tag"] = "success"
+		entries[i] = result
+	}
+
+	return map[string]any{"entries": entries}, nil
+}
+
+var rangePattern = regexp.MustCompile(`^bytes=(\d*)-(\d*)$`)
+
+func (s *Server) download(w http.ResponseWriter, r *http.Request, arg, _ []byte) {
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := decode("files/download", arg, &req); err != nil {
+		err.write(w)
+		return
+	}
+
+	n, rev, err := s.tree.resolve(req.Path)
+	if err == nil && n.folder {
+		err = lookupError("not_file")
+	}
+	if err != nil {
+		tagged("path", err).write(w)
+		return
+	}
+
+	result, jsonErr := json.Marshal(fileMetadata(n, rev))
+	if jsonErr != nil {
+		http.Error(w, jsonErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	content := rev.content
+	status := http.StatusOK
+	if header := r.Header.Get("Range"); header != "" {
+		start, end, ok := parseRange(header, len(content))
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(content)))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, len(content)))
+		content = content[start:end]
+		status = http.StatusPartialContent
+	}
+
+	w.Header().Set("Dropbox-API-Result", sdk.HTTPHeaderSafeJSON(result))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(status)
+	w.Write(content)
+}
+
+// parseRange returns the half-open byte range requested by a Range header
+// for content of the given size, and false if it cannot be satisfied
+func parseRange(header string, size int) (int, int, bool) {
+	match := rangePattern.FindStringSubmatch(header)
+	if match == nil || match[1] == "" && match[2] == "" {
+		return 0, 0, false
+	}
+
+	if match[1] == "" {
+		// A suffix range asks for the last bytes
+		suffix, _ := strconv.Atoi(match[2])
+		if suffix == 0 {
+			return 0, 0, false
+		}
+		return max(size-suffix, 0), size, true
+	}
+
+	start, _ := strconv.Atoi(match[1])
+	end := size
+	if match[2] != "" {
+		last, _ := strconv.Atoi(match[2])
+		end = min(last+1, size)
+	}
+	if start >= size || start >= end {
+		return 0, 0, false
+	}
+
+	return start, end, true
+}
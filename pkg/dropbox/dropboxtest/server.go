@@ -0,0 +1,411 @@
+// Package dropboxtest provides an in-process fake of the Dropbox API for
+// testing code built on dropbox.Client without a live account.
+//
+// The fake keeps an in-memory tree of files and folders and implements the
+// files routes the client uses: listing, metadata, uploads and upload
+// sessions, downloads with byte ranges, delete, create folder, folder
+// batches, move, copy and revisions. Access tokens can be expired and
+// refreshed through a fake OAuth2 token endpoint. Faults such as rate limits and server
+// errors can be injected per route to exercise error handling and retries.
+package dropboxtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"valboks/pkg/dropbox"
+)
+
+// DefaultToken is the access token the server accepts unless WithToken is used
+const DefaultToken = "dropboxtest-token"
+
+// RefreshToken is the refresh token the server's token endpoint accepts
+const RefreshToken = "dropboxtest-refresh-token"
+
+// DefaultPageSize is the number of entries returned per list_folder page
+const DefaultPageSize = 2000
+
+// Server is a fake Dropbox API server with an in-memory file tree
+type Server struct {
+	srv *httptest.Server
+
+	token        string
+	expired      map[string]bool
+	pageSize     int
+	asyncBatches bool
+
+	handlers map[string]route
+
+	mu       sync.Mutex
+	tree     *tree
+	sessions map[string]*uploadSession
+	cursors  map[string][]any
+	jobs     map[string]*batchJob
+	faults   []*injectedFault
+	requests map[string]int
+	nextID   int
+}
+
+// Option configures optional Server behaviour
+type Option func(*Server)
+
+// WithToken makes the server accept token instead of DefaultToken
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithPageSize sets the number of entries returned per list_folder page, so
+// that small trees exercise list_folder/continue. Non-positive values keep the default.
+func WithPageSize(size int) Option {
+	return func(s *Server) {
+		if size > 0 {
+			s.pageSize = size
+		}
+	}
+}
+
+// WithAsyncFolderBatches makes every create_folder_batch request start an
+// asynchronous job, which reports in_progress once before it completes
+func WithAsyncFolderBatches() Option {
+	return func(s *Server) {
+		s.asyncBatches = true
+	}
+}
+
+// NewServer starts a server with an empty Dropbox. Close it when done.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		token:    DefaultToken,
+		expired:  make(map[string]bool),
+		pageSize: DefaultPageSize,
+		tree:     newTree(),
+		sessions: make(map[string]*uploadSession),
+		cursors:  make(map[string][]any),
+		jobs:     make(map[string]*batchJob),
+		requests: make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.handlers = s.routes()
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// URL returns the base URL of the server
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// TokenURL returns the URL of the OAuth2 token endpoint, which issues the
+// current access token in exchange for RefreshToken
+func (s *Server) TokenURL() string {
+	return s.srv.URL + "/oauth2/token"
+}
+
+// ExpireToken makes the server reject the current access token as expired
+// and accept a new one, which clients get from the token endpoint
+func (s *Server) ExpireToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expired[s.token] = true
+	s.nextID++
+	s.token = fmt.Sprintf("%s-%d", DefaultToken, s.nextID)
+}
+
+// ClientOptions returns the options that point a dropbox.Client at the server
+func (s *Server) ClientOptions() []dropbox.Option {
+	return []dropbox.Option{
+		dropbox.WithURLGenerator(func(hostType, namespace, route string) string {
+			return fmt.Sprintf("%s/2/%s/%s", s.srv.URL, namespace, route)
+		}),
+		dropbox.WithHTTPClient(s.srv.Client()),
+	}
+}
+
+// NewClient returns a client authorized for the server. opts are applied
+// after the options returned by ClientOptions.
+func (s *Server) NewClient(opts ...dropbox.Option) *dropbox.Client {
+	return dropbox.NewClient(dropbox.StaticToken(s.token), append(s.ClientOptions(), opts...)...)
+}
+
+// Requests returns how many requests were made to route, for example
+// "files/upload" or "oauth2/token", including requests answered with an injected fault
+func (s *Server) Requests(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[route]
+}
+
+// Fault is a response returned instead of handling a request
+type Fault struct {
+	// Status defaults to 500 Internal Server Error
+	Status int
+	Header http.Header
+	Body   string
+	// Drop closes the connection without responding
+	Drop bool
+	// Handled lets the request take effect before the fault is returned,
+	// as if the response was lost on its way back
+	Handled bool
+}
+
+// RateLimited is the fault Dropbox returns when too many requests are made,
+// asking the client to wait retryAfter seconds
+func RateLimited(retryAfter int) Fault {
+	return rateLimitFault("too_many_requests", retryAfter)
+}
+
+// TooManyWriteOperations is the fault Dropbox returns when concurrent
+// writes to the same namespace are refused
+func TooManyWriteOperations() Fault {
+	return rateLimitFault("too_many_write_operations", 1)
+}
+
+func rateLimitFault(reason string, retryAfter int) Fault {
+	body, _ := json.Marshal(map[string]any{
+		"error_summary": reason + "/",
+		"error": map[string]any{
+			"reason":      map[string]any{".tag": reason},
+			"retry_after": retryAfter,
+		},
+	})
+
+	return Fault{
+		Status: http.StatusTooManyRequests,
+		Header: http.Header{"Retry-After": {fmt.Sprint(retryAfter)}, "Content-Type": {"application/json"}},
+		Body:   string(body),
+	}
+}
+
+// ServerError is a 5xx response with a plain text body
+func ServerError(status int) Fault {
+	return Fault{Status: status, Body: http.StatusText(status)}
+}
+
+// ConnectionReset drops the connection before responding
+func ConnectionReset() Fault {
+	return Fault{Drop: true}
+}
+
+// LostResponse handles the request and then drops the connection, so the
+// client cannot tell that the request succeeded
+func LostResponse() Fault {
+	return Fault{Drop: true, Handled: true}
+}
+
+type injectedFault struct {
+	route     string
+	remaining int
+	fault     Fault
+}
+
+// InjectFault answers the next times requests to route with fault. An empty
+// route matches every route. Faults are used in the order they were injected.
+// Non-positive times inject nothing.
+func (s *Server) InjectFault(route string, times int, fault Fault) {
+	if times <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &injectedFault{route: route, remaining: times, fault: fault})
+}
+
+// takeFault returns the first injected fault matching route, if any
+func (s *Server) takeFault(route string) (Fault, bool) {
+	for i, f := range s.faults {
+		if f.route != "" && f.route != route {
+			continue
+		}
+
+		f.remaining--
+		if f.remaining <= 0 {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+		}
+		return f.fault, true
+	}
+
+	return Fault{}, false
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/oauth2/token" && r.Method == http.MethodPost {
+		s.serveToken(w, r)
+		return
+	}
+
+	route, ok := strings.CutPrefix(r.URL.Path, "/2/")
+	if !ok || r.Method != http.MethodPost {
+		http.Error(w, "Unknown API function", http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[route]++
+
+	if fault, ok := s.takeFault(route); ok {
+		if fault.Handled {
+			s.handle(httptest.NewRecorder(), r, route)
+		}
+		writeFault(w, fault)
+		return
+	}
+
+	s.handle(w, r, route)
+}
+
+// handle answers a request to route. The caller holds s.mu.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request, route string) {
+	if token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); s.expired[token] {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error_summary": "expired_access_token/",
+			"error":         map[string]any{".tag": "expired_access_token"},
+		})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.token {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"error_summary": "invalid_access_token/",
+			"error":         map[string]any{".tag": "invalid_access_token"},
+		})
+		return
+	}
+
+	handler, ok := s.handlers[route]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown API function: %q", route), http.StatusBadRequest)
+		return
+	}
+
+	arg := []byte(r.Header.Get("Dropbox-API-Arg"))
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if handler.style == "rpc" {
+		arg, body = body, nil
+	}
+
+	handler.serve(w, r, arg, body)
+}
+
+// serveToken issues the current access token for a refresh token grant
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests["oauth2/token"]++
+
+	if fault, ok := s.takeFault("oauth2/token"); ok {
+		writeFault(w, fault)
+		return
+	}
+
+	if r.PostFormValue("grant_type") != "refresh_token" || r.PostFormValue("refresh_token") != RefreshToken {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":             "invalid_grant",
+			"error_description": "refresh token is invalid",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": s.token,
+		"token_type":   "bearer",
+		"expires_in":   14400,
+	})
+}
+
+func writeFault(w http.ResponseWriter, fault Fault) {
+	if fault.Drop {
+		if hijacker, ok := w.(http.Hijacker); ok {
+			conn, _, err := hijacker.Hijack()
+			if err == nil {
+				conn.Close()
+				return
+			}
+		}
+		fault.Status = http.StatusBadGateway
+	}
+
+	if fault.Status == 0 {
+		fault.Status = http.StatusInternalServerError
+	}
+
+	for key, values := range fault.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(fault.Status)
+	io.WriteString(w, fault.Body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// apiError is an error response, usually an endpoint specific error with status 409
+type apiError struct {
+	status  int
+	summary string
+	body    any
+}
+
+func (e *apiError) write(w http.ResponseWriter) {
+	if e.body == nil {
+		http.Error(w, e.summary, e.status)
+		return
+	}
+
+	writeJSON(w, e.status, map[string]any{"error_summary": e.summary, "error": e.body})
+}
+
+// tagged returns a 409 error for the union member tag. A nested error, such
+// as a lookup or write error, is added under the same key.
+func tagged(tag string, nested *apiError) *apiError {
+	if nested == nil {
+		return &apiError{status: http.StatusConflict, summary: tag + "/", body: map[string]any{".tag": tag}}
+	}
+
+	return &apiError{
+		status:  http.StatusConflict,
+		summary: tag + "/" + nested.summary,
+		body:    map[string]any{".tag": tag, tag: nested.body},
+	}
+}
+
+// badRequest is the plain text error Dropbox returns for invalid arguments
+func badRequest(route, format string, args ...any) *apiError {
+	return &apiError{
+		status:  http.StatusBadRequest,
+		summary: fmt.Sprintf("Error in call to API function %q: %s", route, fmt.Sprintf(format, args...)),
+	}
+}
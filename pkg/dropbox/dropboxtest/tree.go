@@ -0,0 +1,409 @@
+package dropboxtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"valboks/pkg/dropbox"
+)
+
+// timeFormat is how Dropbox encodes timestamps
+const timeFormat = "2006-01-02T15:04:05Z"
+
+// revision is one version of the content of a file
+type revision struct {
+	rev            string
+	content        []byte
+	contentHash    string
+	clientModified time.Time
+	serverModified time.Time
+}
+
+type node struct {
+	id string
+	// display is the path with the casing it was created with
+	display string
+	folder  bool
+	// current is the revision of a file, nil for folders
+	current *revision
+}
+
+func (n *node) name() string {
+	return path.Base(n.display)
+}
+
+// tree is the in-memory Dropbox. Paths are keyed in lower case, the root
+// folder is implicit.
+type tree struct {
+	nodes map[string]*node
+	// history keeps every revision uploaded to a path, oldest first, also
+	// after the file was deleted
+	history map[string][]*revision
+	next    int
+}
+
+func newTree() *tree {
+	return &tree{
+		nodes:   make(map[string]*node),
+		history: make(map[string][]*revision),
+	}
+}
+
+// counter returns a new number for ids and revisions
+func (t *tree) counter() int {
+	t.next++
+	return t.next
+}
+
+// lookupError returns a LookupError or WriteError with the given tag
+func lookupError(tag string) *apiError {
+	return &apiError{summary: tag + "/", body: map[string]any{".tag": tag}}
+}
+
+// conflictError returns a WriteError for a conflict with an existing file,
+// folder or file_ancestor
+func conflictError(kind string) *apiError {
+	return tagged("conflict", lookupError(kind))
+}
+
+// checkPath validates a path argument and returns it in lower case. The
+// empty path is the root folder.
+func checkPath(p string) (string, *apiError) {
+	if p == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(p, "/") || strings.HasSuffix(p, "/") || strings.Contains(p, "//") {
+		return "", lookupError("malformed_path")
+	}
+
+	return strings.ToLower(p), nil
+}
+
+// resolve finds the node for a path, an "id:" or a "rev:" argument. For
+// revisions the node is the file's current node and rev the requested version.
+func (t *tree) resolve(p string) (*node, *revision, *apiError) {
+	switch {
+	case strings.HasPrefix(p, "id:"):
+		for _, n := range t.nodes {
+			if n.id == p {
+				return n, n.current, nil
+			}
+		}
+		return nil, nil, lookupError("not_found")
+	case strings.HasPrefix(p, "rev:"):
+		rev := strings.TrimPrefix(p, "rev:")
+		for lower, revs := range t.history {
+			for _, r := range revs {
+				if r.rev == rev {
+					n := t.nodes[lower]
+					if n == nil || n.folder {
+						return nil, nil, lookupError("not_found")
+					}
+					return n, r, nil
+				}
+			}
+		}
+		return nil, nil, lookupError("not_found")
+	}
+
+	lower, err := checkPath(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if lower == "" {
+		return &node{folder: true}, nil, nil
+	}
+
+	n, ok := t.nodes[lower]
+	if !ok {
+		return nil, nil, lookupError("not_found")
+	}
+	return n, n.current, nil
+}
+
+// children returns the entries in the folder at lower, or everything below
+// it if recursive is set, sorted by path
+func (t *tree) children(lower string, recursive bool) []*node {
+	var entries []*node
+	for key, n := range t.nodes {
+		if !strings.HasPrefix(key, lower+"/") {
+			continue
+		}
+		if !recursive && strings.Contains(key[len(lower)+1:], "/") {
+			continue
+		}
+		entries = append(entries, n)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].display) < strings.ToLower(entries[j].display)
+	})
+	return entries
+}
+
+// parentDisplay returns the display path of the folder that will contain
+// display, using the casing of folders that already exist
+func (t *tree) parentDisplay(display string) string {
+	parent := path.Dir(display)
+	if parent == "/" {
+		return ""
+	}
+	if n, ok := t.nodes[strings.ToLower(parent)]; ok {
+		return n.display
+	}
+	return t.parentDisplay(parent) + "/" + path.Base(parent)
+}
+
+// checkAncestors returns a file_ancestor conflict if a file is in the way
+// of creating display
+func (t *tree) checkAncestors(display string) *apiError {
+	for parent := path.Dir(display); parent != "/"; parent = path.Dir(parent) {
+		if n, ok := t.nodes[strings.ToLower(parent)]; ok && !n.folder {
+			return conflictError("file_ancestor")
+		}
+	}
+	return nil
+}
+
+// mkdirAll creates the folders leading up to display
+func (t *tree) mkdirAll(display string) {
+	parent := path.Dir(display)
+	if parent == "/" {
+		return
+	}
+	if _, ok := t.nodes[strings.ToLower(parent)]; ok {
+		return
+	}
+
+	t.mkdirAll(parent)
+	t.add(&node{display: t.parentDisplay(parent) + "/" + path.Base(parent), folder: true})
+}
+
+// add stores n under its display path, giving it an id if it has none
+func (t *tree) add(n *node) {
+	if n.id == "" {
+		n.id = fmt.Sprintf("id:%012x", t.counter())
+	}
+	t.nodes[strings.ToLower(n.display)] = n
+}
+
+// remove deletes the node at lower and everything below it
+func (t *tree) remove(lower string) {
+	for key := range t.nodes {
+		if key == lower || strings.HasPrefix(key, lower+"/") {
+			delete(t.nodes, key)
+		}
+	}
+}
+
+// free returns display, or if autorename is set and display is taken, the
+// first free name of the form "name (1).ext"
+func (t *tree) free(display string, autorename bool) (string, bool) {
+	if _, ok := t.nodes[strings.ToLower(display)]; !ok {
+		return display, true
+	}
+	if !autorename {
+		return display, false
+	}
+
+	ext := path.Ext(display)
+	base := strings.TrimSuffix(display, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, ok := t.nodes[strings.ToLower(candidate)]; !ok {
+			return candidate, true
+		}
+	}
+}
+
+// writeMode is the decoded files.WriteMode
+type writeMode struct {
+	Tag    string `json:".tag"`
+	Update string `json:"update"`
+}
+
+// commitInfo is the decoded files.CommitInfo
+type commitInfo struct {
+	Path           string     `json:"path"`
+	Mode           *writeMode `json:"mode"`
+	Autorename     bool       `json:"autorename"`
+	ClientModified *time.Time `json:"client_modified"`
+}
+
+// commit writes content to the file described by info following its write
+// mode, and returns the file's node. Writing the content the file already
+// has does not create a new revision.
+func (t *tree) commit(info commitInfo, content []byte) (*node, *apiError) {
+	lower, err := checkPath(info.Path)
+	if err != nil {
+		return nil, err
+	}
+	if lower == "" {
+		return nil, lookupError("malformed_path")
+	}
+
+	display := t.parentDisplay(info.Path) + "/" + path.Base(info.Path)
+	if err := t.checkAncestors(display); err != nil {
+		return nil, err
+	}
+
+	hash := contentHash(content)
+
+	mode := writeMode{Tag: "add"}
+	if info.Mode != nil {
+		mode = *info.Mode
+	}
+
+	if existing, ok := t.nodes[lower]; ok {
+		conflict := "folder"
+		if !existing.folder {
+			conflict = "file"
+			if existing.current.contentHash == hash {
+				return existing, nil
+			}
+			if mode.Tag == "overwrite" || mode.Tag == "update" && mode.Update == existing.current.rev {
+				display = existing.display
+				conflict = ""
+			}
+		}
+
+		if conflict != "" {
+			free, ok := t.free(display, info.Autorename)
+			if !ok {
+				return nil, conflictError(conflict)
+			}
+			display = free
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	rev := &revision{
+		rev:            fmt.Sprintf("%015x", t.counter()),
+		content:        bytes.Clone(content),
+		contentHash:    hash,
+		clientModified: now,
+		serverModified: now,
+	}
+	if info.ClientModified != nil {
+		rev.clientModified = info.ClientModified.UTC()
+	}
+
+	t.mkdirAll(display)
+
+	key := strings.ToLower(display)
+	n, ok := t.nodes[key]
+	if !ok {
+		n = &node{display: display}
+		t.add(n)
+	}
+	n.current = rev
+	t.history[key] = append(t.history[key], rev)
+
+	return n, nil
+}
+
+func contentHash(data []byte) string {
+	hash, _ := dropbox.ContentHash(bytes.NewReader(data))
+	return hash
+}
+
+// metadata encodes n as files.Metadata
+func metadata(n *node) map[string]any {
+	if n.folder {
+		return map[string]any{
+			".tag":         "folder",
+			"name":         n.name(),
+			"id":           n.id,
+			"path_lower":   strings.ToLower(n.display),
+			"path_display": n.display,
+		}
+	}
+
+	return fileMetadata(n, n.current)
+}
+
+// fileMetadata encodes revision rev of the file n as files.FileMetadata
+func fileMetadata(n *node, rev *revision) map[string]any {
+	return map[string]any{
+		".tag":            "file",
+		"name":            n.name(),
+		"id":              n.id,
+		"path_lower":      strings.ToLower(n.display),
+		"path_display":    n.display,
+		"rev":             rev.rev,
+		"size":            len(rev.content),
+		"content_hash":    rev.contentHash,
+		"client_modified": rev.clientModified.Format(timeFormat),
+		"server_modified": rev.serverModified.Format(timeFormat),
+		"is_downloadable": true,
+	}
+}
+
+// PutFile stores content at p, replacing any file there and creating
+// missing parent folders
+func (s *Server) PutFile(p string, content []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.tree.commit(commitInfo{Path: p, Mode: &writeMode{Tag: "overwrite"}}, content)
+	if err != nil {
+		return errors.New(err.summary)
+	}
+	return nil
+}
+
+// Mkdir creates the folder p and any missing parents
+func (s *Server) Mkdir(p string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lower, err := checkPath(p)
+	if err != nil {
+		return errors.New(err.summary)
+	}
+	if lower == "" {
+		return nil
+	}
+
+	if n, ok := s.tree.nodes[lower]; ok {
+		if !n.folder {
+			return errors.New(conflictError("file").summary)
+		}
+		return nil
+	}
+
+	display := s.tree.parentDisplay(p) + "/" + path.Base(p)
+	if err := s.tree.checkAncestors(display); err != nil {
+		return errors.New(err.summary)
+	}
+	s.tree.mkdirAll(display)
+	s.tree.add(&node{display: display, folder: true})
+	return nil
+}
+
+// ReadFile returns the current content of the file at p, and false if
+// there is no file at p
+func (s *Server) ReadFile(p string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, ok := s.tree.nodes[strings.ToLower(p)]
+	if !ok || n.folder {
+		return nil, false
+	}
+	return bytes.Clone(n.current.content), true
+}
+
+// Exists reports whether there is a file or folder at p
+func (s *Server) Exists(p string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.tree.nodes[strings.ToLower(p)]
+	return ok
+}
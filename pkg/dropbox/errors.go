@@ -0,0 +1,202 @@
+package dropbox
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+	"golang.org/x/oauth2"
+)
+
+// Errors returned by the client can be matched against these with
+// errors.Is to tell why Dropbox refused a request. ErrNotFound and
+// ErrConflict errors also match fs.ErrNotExist and fs.ErrExist.
+var (
+	// ErrNotFound is matched when there is nothing at the requested path
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict is matched when a write was refused because of what
+	// already exists at the destination
+	ErrConflict = errors.New("conflict")
+
+	// ErrInsufficientSpace is matched when the account has no room left for a write
+	ErrInsufficientSpace = errors.New("insufficient space")
+
+	// ErrUnauthorized is matched when the access token is invalid, expired
+	// or revoked and could not be refreshed
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrRateLimited is matched when Dropbox still refused the request
+	// because of too many requests or writes after it was retried
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrMalformedPath is matched when Dropbox does not accept the path
+	ErrMalformedPath = errors.New("malformed path")
+)
+
+// ErrHashMismatch is returned when transferred data does not match the
+// size or content hash Dropbox reports for the file
+var ErrHashMismatch = errors.New("content hash mismatch")
+
+// ConflictError describes a write refused by Dropbox under the requested write mode
+type ConflictError struct {
+	Path string
+	// Reason is what was in the way: "file", "folder" or "file_ancestor"
+	Reason string
+	Err    error
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict writing '%s': existing %s is in the way", e.Path, strings.ReplaceAll(e.Reason, "_", " "))
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict || target == fs.ErrExist
+}
+
+// RequestError is a request refused by Dropbox for a reason that errors.Is
+// matches against Kind, one of the errors above
+type RequestError struct {
+	Kind error
+	Err  error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s (%s)", e.Kind, strings.TrimSuffix(e.Err.Error(), "/"))
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RequestError) Is(target error) bool {
+	switch target {
+	case e.Kind:
+		return true
+	case fs.ErrNotExist:
+		return e.Kind == ErrNotFound
+	case fs.ErrExist:
+		return e.Kind == ErrConflict
+	}
+	return false
+}
+
+// classify converts an error returned by the SDK for a request on path into
+// a ConflictError or RequestError when its reason is one of the errors
+// above, and returns any other error unchanged.
+func classify(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var authErr auth.AuthAPIError
+	var refreshErr *oauth2.RetrieveError
+	var rateLimitErr auth.RateLimitAPIError
+	switch {
+	case errors.Is(err, ErrTokenExpired) || errors.As(err, &authErr):
+		return &RequestError{Kind: ErrUnauthorized, Err: err}
+	case errors.As(err, &refreshErr) && refreshErr.Response != nil && refreshErr.Response.StatusCode < 500:
+		// The refresh token itself was rejected
+		return &RequestError{Kind: ErrUnauthorized, Err: err}
+	case errors.As(err, &rateLimitErr) || isTooManyWriteOperations(err):
+		return &RequestError{Kind: ErrRateLimited, Err: err}
+	}
+
+	lookupErr, writeErr := pathErrors(err)
+	if writeErr != nil {
+		return fromWriteError(path, writeErr, err)
+	}
+	if lookupErr != nil {
+		return fromLookupError(lookupErr, err)
+	}
+
+	return err
+}
+
+// pathErrors returns the lookup or write error reported for the path of the
+// routes used by the client, if err carries one
+func pathErrors(err error) (*files.LookupError, *files.WriteError) {
+	var listErr files.ListFolderAPIError
+	var continueErr files.ListFolderContinueAPIError
+	var metadataErr files.GetMetadataAPIError
+	var downloadErr files.DownloadAPIError
+	var deleteErr files.DeleteV2APIError
+	var createErr files.CreateFolderV2APIError
+	var uploadErr files.UploadAPIError
+	var finishErr files.UploadSessionFinishAPIError
+	var moveErr files.MoveV2APIError
+	var copyErr files.CopyV2APIError
+	switch {
+	case errors.As(err, &listErr) && listErr.EndpointError != nil:
+		return listErr.EndpointError.Path, nil
+	case errors.As(err, &continueErr) && continueErr.EndpointError != nil:
+		return continueErr.EndpointError.Path, nil
+	case errors.As(err, &metadataErr) && metadataErr.EndpointError != nil:
+		return metadataErr.EndpointError.Path, nil
+	case errors.As(err, &downloadErr) && downloadErr.EndpointError != nil:
+		return downloadErr.EndpointError.Path, nil
+	case errors.As(err, &deleteErr) && deleteErr.EndpointError != nil:
+		return deleteErr.EndpointError.PathLookup, deleteErr.EndpointError.PathWrite
+	case errors.As(err, &createErr) && createErr.EndpointError != nil:
+		return nil, createErr.EndpointError.Path
+	case errors.As(err, &uploadErr) && uploadErr.EndpointError != nil && uploadErr.EndpointError.Path != nil:
+		return nil, uploadErr.EndpointError.Path.Reason
+	case errors.As(err, &finishErr) && finishErr.EndpointError != nil:
+		return nil, finishErr.EndpointError.Path
+	case errors.As(err, &moveErr) && moveErr.EndpointError != nil:
+		return relocationErrors(moveErr.EndpointError)
+	case errors.As(err, &copyErr) && copyErr.EndpointError != nil:
+		return relocationErrors(copyErr.EndpointError)
+	}
+
+	return nil, nil
+}
+
+// relocationErrors returns the lookup error for the source or the write
+// error for the source or destination of a move or copy
+func relocationErrors(relocationErr *files.RelocationError) (*files.LookupError, *files.WriteError) {
+	if relocationErr.To != nil {
+		return nil, relocationErr.To
+	}
+	return relocationErr.FromLookup, relocationErr.FromWrite
+}
+
+func fromLookupError(lookupErr *files.LookupError, err error) error {
+	switch lookupErr.Tag {
+	case files.LookupErrorNotFound:
+		return &RequestError{Kind: ErrNotFound, Err: err}
+	case files.LookupErrorMalformedPath:
+		return &RequestError{Kind: ErrMalformedPath, Err: err}
+	}
+
+	return err
+}
+
+// fromWriteError wraps err in a ConflictError or RequestError if writeErr
+// reports a reason matched by one of the errors above
+func fromWriteError(path string, writeErr *files.WriteError, err error) error {
+	if writeErr == nil {
+		return err
+	}
+
+	switch writeErr.Tag {
+	case files.WriteErrorConflict:
+		if writeErr.Conflict != nil {
+			return &ConflictError{Path: path, Reason: writeErr.Conflict.Tag, Err: err}
+		}
+		return &RequestError{Kind: ErrConflict, Err: err}
+	case files.WriteErrorInsufficientSpace:
+		return &RequestError{Kind: ErrInsufficientSpace, Err: err}
+	case files.WriteErrorMalformedPath:
+		return &RequestError{Kind: ErrMalformedPath, Err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,98 @@
+package dropbox
+
+import (
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+type FileInfo struct {
+	Name string
+	// Path is the lower cased path, use PathDisplay for the original casing
+	Path        string
+	PathDisplay string
+	ID          string
+	IsFolder    bool
+	Size        uint64
+
+	// The remaining fields are only set for files
+	Rev string
+	// ClientModified is the modification time the uploading client reported
+	ClientModified time.Time
+	// ServerModified is when the file was last changed on Dropbox
+	ServerModified time.Time
+	ContentHash    string
+	// IsDownloadable is false for files that can only be exported, like Paper docs
+	IsDownloadable bool
+	// SymlinkTarget is the target of a symlink, empty for regular files
+	SymlinkTarget string
+
+	// Sharing is nil unless the entry is shared or inside a shared folder
+	Sharing *SharingInfo
+}
+
+// SharingInfo describes how a shared file or folder can be accessed
+type SharingInfo struct {
+	ReadOnly bool
+	// ParentSharedFolderID is the shared folder containing the entry
+	ParentSharedFolderID string
+	// SharedFolderID is set for folders that are themselves shared folders
+	SharedFolderID string
+	// ModifiedBy is the account ID of the last user to change a file
+	ModifiedBy string
+	// TraverseOnly and NoAccess describe folders the user can only see partially
+	TraverseOnly bool
+	NoAccess     bool
+}
+
+// newFileInfo converts Dropbox metadata into a FileInfo, returning nil for
+// entries that are neither files nor folders, such as deleted entries.
+func newFileInfo(metadata files.IsMetadata) *FileInfo {
+	switch m := metadata.(type) {
+	case *files.FolderMetadata:
+		info := &FileInfo{
+			Name:        m.Name,
+			Path:        m.PathLower,
+			PathDisplay: m.PathDisplay,
+			ID:          m.Id,
+			IsFolder:    true,
+		}
+		if m.SharingInfo != nil {
+			info.Sharing = &SharingInfo{
+				ReadOnly:             m.SharingInfo.ReadOnly,
+				ParentSharedFolderID: m.SharingInfo.ParentSharedFolderId,
+				SharedFolderID:       m.SharingInfo.SharedFolderId,
+				TraverseOnly:         m.SharingInfo.TraverseOnly,
+				NoAccess:             m.SharingInfo.NoAccess,
+			}
+		}
+		return info
+	case *files.FileMetadata:
+		info := &FileInfo{
+			Name:           m.Name,
+			Path:           m.PathLower,
+			PathDisplay:    m.PathDisplay,
+			ID:             m.Id,
+			IsFolder:       false,
+			Size:           m.Size,
+			Rev:            m.Rev,
+			ClientModified: m.ClientModified,
+			ServerModified: m.ServerModified,
+			ContentHash:    m.ContentHash,
+			IsDownloadable: m.IsDownloadable,
+		}
+		if m.SymlinkInfo != nil {
+			info.SymlinkTarget = m.SymlinkInfo.Target
+		}
+		if m.SharingInfo != nil {
+			info.Sharing = &SharingInfo{
+				ReadOnly:             m.SharingInfo.ReadOnly,
+				ParentSharedFolderID: m.SharingInfo.ParentSharedFolderId,
+				ModifiedBy:           m.SharingInfo.ModifiedBy,
+			}
+		}
+		return info
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,106 @@
+package dropbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/async"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+// batchPollInterval is how long CreateFolderBatch waits between checks of an
+// asynchronous batch job
+const batchPollInterval = time.Second
+
+// CreateFolderBatch creates the folders at paths, and their missing
+// parents, with a single request. When Dropbox finishes the batch in the
+// background the job is polled until it completes. The result holds one
+// error per path in the order given, nil for every folder that was created.
+// The returned error reports the batch as a whole failing.
+func (c *Client) CreateFolderBatch(ctx context.Context, paths []string) ([]error, error) {
+	normalized := make([]string, len(paths))
+	for i, path := range paths {
+		normalized[i] = normalizePath(path)
+	}
+
+	batchArg := files.NewCreateFolderBatchArg(normalized)
+	var launch *files.CreateFolderBatchLaunch
+	err := c.retry(ctx, func() (err error) {
+		launch, err = c.files(ctx).CreateFolderBatch(batchArg)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create folders: %w", classify("", err))
+	}
+
+	result := launch.Complete
+	if launch.Tag == files.CreateFolderBatchLaunchAsyncJobId {
+		result, err = c.CreateFolderBatchCheck(ctx, launch.AsyncJobId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if result == nil {
+		return nil, fmt.Errorf("failed to create folders: unexpected result '%s'", launch.Tag)
+	}
+
+	errs := make([]error, len(normalized))
+	for i, path := range normalized {
+		if i >= len(result.Entries) {
+			errs[i] = fmt.Errorf("no result for '%s' in folder batch", path)
+			continue
+		}
+
+		entry := result.Entries[i]
+		if entry.Tag == files.CreateFolderBatchResultEntrySuccess {
+			continue
+		}
+
+		errs[i] = fmt.Errorf("failed to create a folder '%s': %s", path, entry.Tag)
+		if failure := entry.Failure; failure != nil {
+			errs[i] = fromWriteError(path, failure.Path, fmt.Errorf("failed to create a folder '%s': %s", path, failure.Tag))
+		}
+	}
+
+	return errs, nil
+}
+
+// CreateFolderBatchCheck polls the asynchronous folder batch job jobID
+// until it completes and returns its result
+func (c *Client) CreateFolderBatchCheck(ctx context.Context, jobID string) (*files.CreateFolderBatchResult, error) {
+	pollArg := &async.PollArg{AsyncJobId: jobID}
+
+	for {
+		var status *files.CreateFolderBatchJobStatus
+		err := c.retry(ctx, func() (err error) {
+			status, err = c.files(ctx).CreateFolderBatchCheck(pollArg)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to check folder batch job: %w", classify("", err))
+		}
+
+		switch status.Tag {
+		case files.CreateFolderBatchJobStatusComplete:
+			return status.Complete, nil
+		case files.CreateFolderBatchJobStatusFailed:
+			reason := "unknown reason"
+			if status.Failed != nil {
+				reason = status.Failed.Tag
+			}
+			return nil, fmt.Errorf("folder batch job failed: %s", reason)
+		case files.CreateFolderBatchJobStatusInProgress:
+		default:
+			return nil, fmt.Errorf("folder batch job returned unexpected status '%s'", status.Tag)
+		}
+
+		timer := time.NewTimer(batchPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("failed to check folder batch job: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
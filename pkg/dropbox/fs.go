@@ -0,0 +1,309 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long an FS reuses a folder listing when none is configured
+const DefaultCacheTTL = 30 * time.Second
+
+// errIsDir is returned when reading a folder opened through an FS as a file
+var errIsDir = errors.New("is a directory")
+
+// FSOptions controls how an FS caches folder listings
+type FSOptions struct {
+	// CacheTTL is how long a folder listing is reused before it is fetched
+	// again. Zero uses DefaultCacheTTL, negative values disable the cache.
+	CacheTTL time.Duration
+}
+
+func (o FSOptions) cacheTTL() time.Duration {
+	if o.CacheTTL == 0 {
+		return DefaultCacheTTL
+	}
+	return o.CacheTTL
+}
+
+// FS is a read-only view of a Dropbox folder that implements fs.FS,
+// fs.ReadDirFS and fs.StatFS. Files are streamed from Dropbox as they are
+// read. Folder listings are cached, so changes made on Dropbox can take up
+// to the cache TTL to show.
+type FS struct {
+	ctx    context.Context
+	client *Client
+	root   string
+	ttl    time.Duration
+
+	mu sync.Mutex
+	// dirs holds the cached listings keyed by lower cased Dropbox path
+	dirs map[string]cachedDir
+}
+
+type cachedDir struct {
+	entries []FileInfo
+	expires time.Time
+}
+
+// NewFS returns the folder root of client as an FS. Every request made
+// through the FS is part of ctx.
+func NewFS(ctx context.Context, client *Client, root string, opts FSOptions) *FS {
+	return &FS{
+		ctx:    ctx,
+		client: client,
+		root:   normalizePath(root),
+		ttl:    opts.cacheTTL(),
+		dirs:   make(map[string]cachedDir),
+	}
+}
+
+// dropboxPath returns the Dropbox path of the FS path name
+func (f *FS) dropboxPath(name string) string {
+	if name == "." {
+		return f.root
+	}
+	return f.root + "/" + name
+}
+
+// Open opens the file or folder at name. Files are only downloaded once
+// they are read.
+func (f *FS) Open(name string) (fs.File, error) {
+	info, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return &fsFile{fsys: f, name: name, info: info}, nil
+	}
+
+	entries, err := f.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return &fsDir{name: name, info: info, entries: entries}, nil
+}
+
+// Stat describes the file or folder at name, from a cached listing of its
+// parent folder when there is one
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	return f.stat("stat", name)
+}
+
+func (f *FS) stat(op, name string) (*fsInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." && f.root == "" {
+		// Dropbox has no metadata for the root folder
+		return &fsInfo{name: ".", info: &FileInfo{IsFolder: true}}, nil
+	}
+
+	if name != "." {
+		if entries, ok := f.cached(f.dropboxPath(path.Dir(name))); ok {
+			base := path.Base(name)
+			for i := range entries {
+				if strings.EqualFold(entries[i].Name, base) {
+					return &fsInfo{name: entries[i].Name, info: &entries[i]}, nil
+				}
+			}
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+
+	info, err := f.client.GetFileInfo(f.ctx, f.dropboxPath(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	result := &fsInfo{name: info.Name, info: info}
+	if name == "." {
+		result.name = "."
+	}
+	return result, nil
+}
+
+// ReadDir returns the entries of the folder at name sorted by name
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	dropboxPath := f.dropboxPath(name)
+	entries, ok := f.cached(dropboxPath)
+	if !ok {
+		var err error
+		entries, err = f.client.ListFolder(f.ctx, dropboxPath)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		f.store(dropboxPath, entries)
+	}
+
+	dirEntries := make([]fs.DirEntry, len(entries))
+	for i := range entries {
+		dirEntries[i] = &fsInfo{name: entries[i].Name, info: &entries[i]}
+	}
+	return dirEntries, nil
+}
+
+// cached returns the listing of the folder at dropboxPath unless it has expired
+func (f *FS) cached(dropboxPath string) ([]FileInfo, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.ToLower(dropboxPath)
+	dir, ok := f.dirs[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(dir.expires) {
+		delete(f.dirs, key)
+		return nil, false
+	}
+	return dir.entries, true
+}
+
+func (f *FS) store(dropboxPath string, entries []FileInfo) {
+	if f.ttl < 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.dirs[strings.ToLower(dropboxPath)] = cachedDir{entries: entries, expires: time.Now().Add(f.ttl)}
+}
+
+// fsInfo describes a Dropbox entry as an fs.FileInfo and an fs.DirEntry
+type fsInfo struct {
+	name string
+	info *FileInfo
+}
+
+func (i *fsInfo) Name() string { return i.name }
+func (i *fsInfo) Size() int64  { return int64(i.info.Size) }
+func (i *fsInfo) IsDir() bool  { return i.info.IsFolder }
+
+func (i *fsInfo) Mode() fs.FileMode {
+	if i.info.IsFolder {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (i *fsInfo) ModTime() time.Time {
+	if !i.info.ClientModified.IsZero() {
+		return i.info.ClientModified
+	}
+	return i.info.ServerModified
+}
+
+// Sys returns the *FileInfo of the entry
+func (i *fsInfo) Sys() any { return i.info }
+
+func (i *fsInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i *fsInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// fsFile is a file opened through an FS, downloaded on the first Read
+type fsFile struct {
+	fsys    *FS
+	name    string
+	info    *fsInfo
+	content io.ReadCloser
+	closed  bool
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	return f.info, nil
+}
+
+func (f *fsFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	if f.content == nil {
+		_, content, err := f.fsys.client.OpenFile(f.fsys.ctx, f.info.info.PathDisplay)
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.content = content
+	}
+
+	return f.content.Read(p)
+}
+
+func (f *fsFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+
+	if f.content != nil {
+		return f.content.Close()
+	}
+	return nil
+}
+
+// fsDir is a folder opened through an FS
+type fsDir struct {
+	name    string
+	info    *fsInfo
+	entries []fs.DirEntry
+	// offset is the number of entries already returned by ReadDir
+	offset int
+	closed bool
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "stat", Path: d.name, Err: fs.ErrClosed}
+	}
+	return d.info, nil
+}
+
+func (d *fsDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errIsDir}
+}
+
+// ReadDir returns the next n entries, or all remaining ones if n <= 0
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrClosed}
+	}
+
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(remaining))
+	d.offset += n
+	return remaining[:n], nil
+}
+
+func (d *fsDir) Close() error {
+	if d.closed {
+		return &fs.PathError{Op: "close", Path: d.name, Err: fs.ErrClosed}
+	}
+	d.closed = true
+	return nil
+}
@@ -0,0 +1,98 @@
+package dropbox_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"valboks/pkg/dropbox"
+	"valboks/pkg/dropbox/dropboxtest"
+)
+
+// newTestTree fills a fake server with nested folders below /root
+func newTestTree(t *testing.T) (*dropboxtest.Server, *dropbox.Client) {
+	t.Helper()
+
+	server, client := newTestClient(t, []dropboxtest.Option{dropboxtest.WithPageSize(2)})
+	for _, p := range []string{"/root/a.txt", "/root/B.md", "/root/sub/c.txt", "/root/sub/deep/d.txt", "/outside.txt"} {
+		putFile(t, server, p, []byte("content of "+p))
+	}
+	if err := server.Mkdir("/root/empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	return server, client
+}
+
+func TestFS(t *testing.T) {
+	tests := []struct {
+		name string
+		opts dropbox.FSOptions
+	}{
+		{"cached", dropbox.FSOptions{}},
+		{"uncached", dropbox.FSOptions{CacheTTL: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestTree(t)
+
+			fsys := dropbox.NewFS(context.Background(), client, "/root", tt.opts)
+			err := fstest.TestFS(fsys, "a.txt", "B.md", "sub/c.txt", "sub/deep/d.txt", "empty")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			root := dropbox.NewFS(context.Background(), client, "/", tt.opts)
+			err = fstest.TestFS(root, "outside.txt", "root/a.txt", "root/sub/deep/d.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestFSCachesListings(t *testing.T) {
+	server, client := newTestTree(t)
+	fsys := dropbox.NewFS(context.Background(), client, "/root", dropbox.FSOptions{})
+
+	walk := func() {
+		err := fs.WalkDir(fsys, ".", func(string, fs.DirEntry, error) error { return nil })
+		if err != nil {
+			t.Fatalf("WalkDir: %v", err)
+		}
+	}
+
+	walk()
+	listed := server.Requests("files/list_folder")
+	walk()
+	if got := server.Requests("files/list_folder"); got != listed {
+		t.Errorf("list_folder requests grew from %d to %d on a cached walk", listed, got)
+	}
+
+	// Stat is answered from the cached listing of the parent folder
+	stats := server.Requests("files/get_metadata")
+	if _, err := fs.Stat(fsys, "sub/c.txt"); err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if got := server.Requests("files/get_metadata"); got != stats {
+		t.Errorf("get_metadata requests grew from %d to %d for a cached entry", stats, got)
+	}
+}
+
+func TestFSNotExist(t *testing.T) {
+	_, client := newTestTree(t)
+	fsys := dropbox.NewFS(context.Background(), client, "/root", dropbox.FSOptions{})
+
+	_, err := fsys.Open("missing.txt")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open error = %v, want fs.ErrNotExist", err)
+	}
+
+	_, err = fsys.Open("../outside.txt")
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Open of an invalid path error = %v, want fs.ErrInvalid", err)
+	}
+}
@@ -0,0 +1,156 @@
+package dropbox
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// OAuthEndpoint is where Dropbox authorizes apps and issues tokens
+var OAuthEndpoint = oauth2.Endpoint{
+	AuthURL:   "https://www.dropbox.com/oauth2/authorize",
+	TokenURL:  "https://api.dropboxapi.com/oauth2/token",
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// CallbackPath is the path the loopback redirect server listens on
+const CallbackPath = "/callback"
+
+// Token is the result of a successful authorization
+type Token struct {
+	AccessToken string
+	// RefreshToken is long lived and used to get new access tokens
+	RefreshToken string
+	// Expiry is when AccessToken stops working
+	Expiry    time.Time
+	AccountID string
+}
+
+// Authorizer runs the OAuth2 authorization code flow with PKCE. The app
+// secret is optional, PKCE lets public clients authorize without one.
+type Authorizer struct {
+	config   *oauth2.Config
+	verifier string
+	state    string
+}
+
+// NewAuthorizer prepares an authorization. redirectURL is empty when the
+// user copies the code from the Dropbox page instead of being redirected.
+func NewAuthorizer(appKey, appSecret, redirectURL string) (*Authorizer, error) {
+	verifier, err := randomString(64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate code verifier: %w", err)
+	}
+
+	state, err := randomString(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate state: %w", err)
+	}
+
+	return &Authorizer{
+		config: &oauth2.Config{
+			ClientID:     appKey,
+			ClientSecret: appSecret,
+			Endpoint:     OAuthEndpoint,
+			RedirectURL:  redirectURL,
+		},
+		verifier: verifier,
+		state:    state,
+	}, nil
+}
+
+// AuthURL returns the page the user has to visit to grant access
+func (a *Authorizer) AuthURL() string {
+	challenge := sha256.Sum256([]byte(a.verifier))
+
+	return a.config.AuthCodeURL(a.state,
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		// offline access is what makes Dropbox return a refresh token
+		oauth2.SetAuthURLParam("token_access_type", "offline"),
+	)
+}
+
+// Exchange trades an authorization code for tokens
+func (a *Authorizer) Exchange(ctx context.Context, code string) (*Token, error) {
+	token, err := a.config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", a.verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	accountID, _ := token.Extra("account_id").(string)
+	return &Token{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		Expiry:       token.Expiry,
+		AccountID:    accountID,
+	}, nil
+}
+
+// WaitForCode serves the redirect on listener until Dropbox sends the
+// user back with an authorization code or ctx is done.
+func (a *Authorizer) WaitForCode(ctx context.Context, listener net.Listener) (string, error) {
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(CallbackPath, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		var res result
+		switch {
+		case query.Get("state") != a.state:
+			res.err = errors.New("authorization response has an unexpected state")
+		case query.Get("error") != "":
+			res.err = fmt.Errorf("authorization was denied: %s %s", query.Get("error"), query.Get("error_description"))
+		case query.Get("code") == "":
+			res.err = errors.New("authorization response has no code")
+		default:
+			res.code = query.Get("code")
+		}
+
+		if res.err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "<html><body><p>Authorization failed: %s</p></body></html>", html.EscapeString(res.err.Error()))
+		} else {
+			fmt.Fprint(w, "<html><body><p>Authorization complete, you can close this window.</p></body></html>")
+		}
+
+		select {
+		case results <- res:
+		default:
+		}
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	defer server.Close()
+
+	select {
+	case res := <-results:
+		return res.code, res.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("timed out waiting for authorization: %w", ctx.Err())
+	}
+}
+
+func randomString(size int) (string, error) {
+	data := make([]byte, size)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
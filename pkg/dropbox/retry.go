@@ -0,0 +1,180 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/auth"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+const (
+	// DefaultMaxAttempts is how often a request is sent before giving up when no policy is configured
+	DefaultMaxAttempts = 5
+
+	// DefaultRetryBudget is the longest time spent waiting between attempts of one request
+	DefaultRetryBudget = 2 * time.Minute
+)
+
+// errTooManyWriteOperations reports batch entries that lost a race for the
+// namespace lock and should be committed again
+var errTooManyWriteOperations = errors.New("too_many_write_operations")
+
+// RetryPolicy controls how requests that failed for a transient reason are
+// retried. Rate limited requests wait as long as Dropbox asks, other
+// transient failures back off exponentially with jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a request is sent, 1 disables retries
+	MaxAttempts int
+	// Budget is the total time a request may spend waiting for retries
+	Budget time.Duration
+	// BaseDelay is the backoff before the first retry, it doubles with every attempt up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// OnRetry is called before waiting for a retry, if set
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// DefaultRetryPolicy is used by clients created without WithRetryPolicy
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: DefaultMaxAttempts,
+	Budget:      DefaultRetryBudget,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// WithRetryPolicy replaces the default retry policy. Zero fields keep
+// their default values.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		if policy.MaxAttempts <= 0 {
+			policy.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+		}
+		if policy.Budget <= 0 {
+			policy.Budget = DefaultRetryPolicy.Budget
+		}
+		if policy.BaseDelay <= 0 {
+			policy.BaseDelay = DefaultRetryPolicy.BaseDelay
+		}
+		if policy.MaxDelay <= 0 {
+			policy.MaxDelay = DefaultRetryPolicy.MaxDelay
+		}
+		c.retryPolicy = policy
+	}
+}
+
+// retry calls fn until it succeeds, fails with an error that is not
+// transient, or the policy runs out of attempts or budget. fn must wrap a
+// single SDK call so the error it returns can be classified.
+func (c *Client) retry(ctx context.Context, fn func() error) error {
+	policy := c.retryPolicy
+	var waited time.Duration
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		delay, ok := policy.delay(err, attempt)
+		if !ok || waited+delay > policy.Budget {
+			return err
+		}
+
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, delay, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		waited += delay
+	}
+}
+
+// delay returns how long to wait before retrying after err, and false if
+// err is not worth retrying
+func (p RetryPolicy) delay(err error, attempt int) (time.Duration, bool) {
+	var rateLimit auth.RateLimitAPIError
+	if errors.As(err, &rateLimit) {
+		if rateLimit.RateLimitError != nil && rateLimit.RateLimitError.RetryAfter > 0 {
+			return time.Duration(rateLimit.RateLimitError.RetryAfter) * time.Second, true
+		}
+		return p.backoff(attempt), true
+	}
+
+	if isTransient(err) {
+		return p.backoff(attempt), true
+	}
+
+	return 0, false
+}
+
+// backoff returns an exponentially growing delay with jitter, so clients
+// that failed together do not retry together
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.MaxDelay
+	if shift := attempt - 1; shift < 32 && p.BaseDelay<<shift < p.MaxDelay {
+		delay = p.BaseDelay << shift
+	}
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// isTransient reports whether err is a server or network failure that may
+// not happen again
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrTokenExpired) {
+		return false
+	}
+
+	var serverErr auth.ServerError
+	if errors.As(err, &serverErr) {
+		return true
+	}
+
+	if isTooManyWriteOperations(err) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isTooManyWriteOperations reports whether a write lost a race for the
+// namespace lock. Besides rate limit responses, routes report it in their
+// own error type and batches in the tag of a result entry.
+func isTooManyWriteOperations(err error) bool {
+	var rateLimitErr auth.RateLimitAPIError
+	if errors.As(err, &rateLimitErr) {
+		reason := rateLimitErr.RateLimitError
+		return reason != nil && reason.Reason != nil && reason.Reason.Tag == auth.RateLimitReasonTooManyWriteOperations
+	}
+
+	var deleteErr files.DeleteV2APIError
+	var finishErr files.UploadSessionFinishAPIError
+	switch {
+	case errors.Is(err, errTooManyWriteOperations):
+		return true
+	case errors.As(err, &deleteErr) && deleteErr.EndpointError != nil:
+		if deleteErr.EndpointError.Tag == files.DeleteErrorTooManyWriteOperations {
+			return true
+		}
+	case errors.As(err, &finishErr) && finishErr.EndpointError != nil:
+		if finishErr.EndpointError.Tag == files.UploadSessionFinishErrorTooManyWriteOperations {
+			return true
+		}
+	}
+
+	_, writeErr := pathErrors(err)
+	return writeErr != nil && writeErr.Tag == files.WriteErrorTooManyWriteOperations
+}
@@ -0,0 +1,102 @@
+package dropbox_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"valboks/pkg/dropbox"
+	"valboks/pkg/dropbox/dropboxtest"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   time.Duration
+		times    int
+		wantErr  error
+		requests int
+		delays   []time.Duration
+	}{
+		{"waits retry_after", 0, 1, nil, 2, []time.Duration{time.Second}},
+		{"stops when the budget is spent", 1500 * time.Millisecond, 5, dropbox.ErrRateLimited, 2, []time.Duration{time.Second}},
+		{"retry_after longer than the budget", 500 * time.Millisecond, 1, dropbox.ErrRateLimited, 1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var delays []time.Duration
+			server, client := newTestClient(t, nil, dropbox.WithRetryPolicy(dropbox.RetryPolicy{
+				MaxAttempts: 10,
+				Budget:      tt.budget,
+				OnRetry: func(attempt int, delay time.Duration, err error) {
+					mu.Lock()
+					defer mu.Unlock()
+					delays = append(delays, delay)
+				},
+			}))
+			if err := server.Mkdir("/folder"); err != nil {
+				t.Fatal(err)
+			}
+			server.InjectFault("files/get_metadata", tt.times, dropboxtest.RateLimited(1))
+
+			_, err := client.GetFileInfo(context.Background(), "/folder")
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("GetFileInfo: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetFileInfo error = %v, want %v", err, tt.wantErr)
+			}
+			if got := server.Requests("files/get_metadata"); got != tt.requests {
+				t.Errorf("get_metadata requests = %d, want %d", got, tt.requests)
+			}
+			if !slices.Equal(delays, tt.delays) {
+				t.Errorf("retry delays = %v, want %v", delays, tt.delays)
+			}
+		})
+	}
+}
+
+func TestRetryEndpointErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      string
+		wantErr  error
+		requests int
+	}{
+		{"too many write operations is retried", "too_many_write_operations", nil, 2},
+		{"other endpoint errors are not", "path_lookup", dropbox.ErrNotFound, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestClient(t, nil)
+			putFile(t, server, "/file.txt", []byte("x"))
+
+			body := `{"error_summary": "` + tt.tag + `/", "error": {".tag": "` + tt.tag + `", "path_lookup": {".tag": "not_found"}}}`
+			server.InjectFault("files/delete_v2", 1, dropboxtest.Fault{
+				Status: http.StatusConflict,
+				Header: http.Header{"Content-Type": {"application/json"}},
+				Body:   body,
+			})
+
+			err := client.DeletePath(context.Background(), "/file.txt")
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("DeletePath: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeletePath error = %v, want %v", err, tt.wantErr)
+			}
+			if got := server.Requests("files/delete_v2"); got != tt.requests {
+				t.Errorf("delete_v2 requests = %d, want %d", got, tt.requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package dropbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SessionLifetime is how long Dropbox keeps an upload session open
+const SessionLifetime = 7 * 24 * time.Hour
+
+// UploadSession records the progress of an interrupted upload so that a
+// later run can continue from the last offset Dropbox acknowledged.
+type UploadSession struct {
+	SessionID   string    `json:"session_id"`
+	LocalPath   string    `json:"local_path"`
+	DropboxPath string    `json:"dropbox_path"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	Offset      uint64    `json:"offset"`
+	StartedAt   time.Time `json:"started_at"`
+}
+
+// Expired reports whether Dropbox has already discarded the session
+func (s *UploadSession) Expired(now time.Time) bool {
+	return now.Sub(s.StartedAt) >= SessionLifetime
+}
+
+// matches reports whether the session was started for the file described by info
+func (s *UploadSession) matches(info os.FileInfo) bool {
+	return s.Size == info.Size() && s.ModTime.Equal(info.ModTime())
+}
+
+// SessionStore persists upload sessions between runs
+type SessionStore interface {
+	// Get returns the session for the given transfer, or nil if there is none
+	Get(localPath, dropboxPath string) (*UploadSession, error)
+	Put(session *UploadSession) error
+	Remove(localPath, dropboxPath string) error
+}
+
+// FileSessionStore keeps upload sessions in a JSON state file
+type FileSessionStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSessionStore(path string) *FileSessionStore {
+	return &FileSessionStore{path: path}
+}
+
+func (s *FileSessionStore) Get(localPath, dropboxPath string) (*UploadSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	session, ok := sessions[sessionKey(localPath, dropboxPath)]
+	if !ok || session.Expired(time.Now()) {
+		return nil, nil
+	}
+
+	return session, nil
+}
+
+func (s *FileSessionStore) Put(session *UploadSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	sessions[sessionKey(session.LocalPath, session.DropboxPath)] = session
+	return s.save(sessions)
+}
+
+func (s *FileSessionStore) Remove(localPath, dropboxPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	delete(sessions, sessionKey(localPath, dropboxPath))
+	return s.save(sessions)
+}
+
+// load reads the state file, dropping any sessions that have expired
+func (s *FileSessionStore) load() (map[string]*UploadSession, error) {
+	sessions := make(map[string]*UploadSession)
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return sessions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading upload state file: %w", err)
+	}
+
+	err = json.Unmarshal(data, &sessions)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing upload state file: %w", err)
+	}
+
+	now := time.Now()
+	for key, session := range sessions {
+		if session.Expired(now) {
+			delete(sessions, key)
+		}
+	}
+
+	return sessions, nil
+}
+
+func (s *FileSessionStore) save(sessions map[string]*UploadSession) error {
+	if len(sessions) == 0 {
+		err := os.Remove(s.path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing upload state file: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing upload state: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0600)
+	if err != nil {
+		return fmt.Errorf("error writing upload state file: %w", err)
+	}
+
+	err = os.Rename(tmpPath, s.path)
+	if err != nil {
+		return fmt.Errorf("error writing upload state file: %w", err)
+	}
+
+	return nil
+}
+
+func sessionKey(localPath, dropboxPath string) string {
+	return filepath.Clean(localPath) + " -> " + dropboxPath
+}
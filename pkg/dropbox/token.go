@@ -0,0 +1,191 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrTokenExpired is returned when the access token expired and cannot be refreshed
+var ErrTokenExpired = errors.New("access token expired - run 'auth' command again")
+
+// TokenSource supplies the access token sent with every request
+type TokenSource interface {
+	// Token returns the current access token
+	Token() (string, error)
+	// Refresh returns a new access token after Dropbox rejected expired as
+	// expired. ctx is the context of the rejected request. Implementations
+	// must be safe for concurrent use, if several requests fail with the
+	// same token only one refresh should happen.
+	Refresh(ctx context.Context, expired string) (string, error)
+}
+
+type staticToken string
+
+// StaticToken returns a TokenSource for a fixed access token that cannot be refreshed
+func StaticToken(accessToken string) TokenSource {
+	return staticToken(accessToken)
+}
+
+func (t staticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+func (t staticToken) Refresh(ctx context.Context, expired string) (string, error) {
+	return "", ErrTokenExpired
+}
+
+// RefreshingTokenSource gets new access tokens with a refresh token
+type RefreshingTokenSource struct {
+	config       *oauth2.Config
+	onRefresh    func(accessToken, refreshToken string) error
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+}
+
+// NewRefreshingTokenSource returns a TokenSource that starts with
+// accessToken and refreshes it with refreshToken and the app credentials.
+// appSecret may be empty for apps authorized with PKCE. If onRefresh is
+// not nil it is called with every new token, for example to save it.
+func NewRefreshingTokenSource(appKey, appSecret, accessToken, refreshToken string, onRefresh func(accessToken, refreshToken string) error) *RefreshingTokenSource {
+	return &RefreshingTokenSource{
+		config: &oauth2.Config{
+			ClientID:     appKey,
+			ClientSecret: appSecret,
+			Endpoint:     OAuthEndpoint,
+		},
+		onRefresh:    onRefresh,
+		accessToken:  accessToken,
+		refreshToken: refreshToken,
+	}
+}
+
+func (s *RefreshingTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accessToken, nil
+}
+
+// SetTokenURL makes the source request new tokens from tokenURL instead of
+// the Dropbox token endpoint. It must be called before the source is used.
+func (s *RefreshingTokenSource) SetTokenURL(tokenURL string) {
+	s.config.Endpoint.TokenURL = tokenURL
+}
+
+func (s *RefreshingTokenSource) Refresh(ctx context.Context, expired string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Another request already replaced the expired token
+	if s.accessToken != expired {
+		return s.accessToken, nil
+	}
+
+	if s.refreshToken == "" {
+		return "", ErrTokenExpired
+	}
+
+	token, err := s.config.TokenSource(ctx, &oauth2.Token{RefreshToken: s.refreshToken}).Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh access token: %w", err)
+	}
+
+	s.accessToken = token.AccessToken
+	// Dropbox keeps the refresh token, the oauth2 package carries the old one over
+	if token.RefreshToken != "" {
+		s.refreshToken = token.RefreshToken
+	}
+
+	if s.onRefresh != nil {
+		err = s.onRefresh(s.accessToken, s.refreshToken)
+		if err != nil {
+			return "", fmt.Errorf("failed to save refreshed access token: %w", err)
+		}
+	}
+
+	return s.accessToken, nil
+}
+
+// tokenTransport authorizes requests with the current token and retries
+// once with a refreshed token when Dropbox reports it as expired
+type tokenTransport struct {
+	tokens TokenSource
+	base   http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	getBody := req.GetBody
+	if getBody == nil && req.Body != nil && req.Body != http.NoBody {
+		// RPC arguments are set without GetBody, keep a copy to be able to resend them
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		getBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+
+	token, err := t.tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.send(req, getBody, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if !isExpiredToken(body) {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+
+	token, err = t.tokens.Refresh(req.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.send(req, getBody, token)
+}
+
+func (t *tokenTransport) send(req *http.Request, getBody func() (io.ReadCloser, error), token string) (*http.Response, error) {
+	clone := req.Clone(req.Context())
+	if getBody != nil {
+		body, err := getBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	clone.Header.Set("Authorization", "Bearer "+token)
+
+	return t.base.RoundTrip(clone)
+}
+
+// isExpiredToken reports whether a 401 response body is Dropbox's expired token error
+func isExpiredToken(body []byte) bool {
+	var authError struct {
+		Error struct {
+			Tag string `json:".tag"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &authError) != nil {
+		return false
+	}
+	return authError.Error.Tag == "expired_access_token"
+}
@@ -0,0 +1,105 @@
+package dropbox_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"valboks/pkg/dropbox"
+	"valboks/pkg/dropbox/dropboxtest"
+)
+
+func TestRefreshExpiredTokenOnce(t *testing.T) {
+	server := dropboxtest.NewServer()
+	t.Cleanup(server.Close)
+	if err := server.Mkdir("/folder"); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var saved []string
+	tokens := dropbox.NewRefreshingTokenSource("key", "secret", dropboxtest.DefaultToken, dropboxtest.RefreshToken,
+		func(accessToken, refreshToken string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			saved = append(saved, accessToken)
+			return nil
+		})
+	tokens.SetTokenURL(server.TokenURL())
+	client := dropbox.NewClient(tokens, append(server.ClientOptions(), fastRetries)...)
+
+	server.ExpireToken()
+
+	const requests = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GetFileInfo(context.Background(), "/folder")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetFileInfo: %v", err)
+		}
+	}
+	if got := server.Requests("oauth2/token"); got != 1 {
+		t.Errorf("token requests = %d, want 1", got)
+	}
+	if len(saved) != 1 {
+		t.Fatalf("onRefresh was called %d times, want 1", len(saved))
+	}
+	if current, _ := tokens.Token(); current != saved[0] || current == dropboxtest.DefaultToken {
+		t.Errorf("current token = %q, want the refreshed token %q", current, saved[0])
+	}
+}
+
+func TestRefreshFollowsRequestContext(t *testing.T) {
+	server := dropboxtest.NewServer()
+	t.Cleanup(server.Close)
+
+	// A token endpoint that does not answer until the test is over
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(hanging.Close)
+	t.Cleanup(func() { close(release) })
+
+	tokens := dropbox.NewRefreshingTokenSource("key", "", dropboxtest.DefaultToken, dropboxtest.RefreshToken, nil)
+	tokens.SetTokenURL(hanging.URL)
+	client := dropbox.NewClient(tokens, append(server.ClientOptions(), fastRetries)...)
+
+	server.ExpireToken()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.GetFileInfo(ctx, "/")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("GetFileInfo error = %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("token refresh ignored the request context")
+	}
+}
@@ -0,0 +1,424 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox"
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+const (
+	// MaxChunkSize is the largest body the upload endpoints accept in a single request
+	MaxChunkSize = 150 * 1024 * 1024
+
+	// DefaultChunkSize is the upload session chunk size used when none is configured
+	DefaultChunkSize = 16 * 1024 * 1024
+)
+
+// WriteMode selects what happens when the upload destination already exists
+type WriteMode string
+
+const (
+	// WriteModeAdd never overwrites, a conflict is reported unless autorename is set
+	WriteModeAdd WriteMode = files.WriteModeAdd
+	// WriteModeOverwrite always replaces the existing file
+	WriteModeOverwrite WriteMode = files.WriteModeOverwrite
+	// WriteModeUpdate replaces the existing file only if it is still at UploadOptions.Rev
+	WriteModeUpdate WriteMode = files.WriteModeUpdate
+)
+
+// UploadOptions controls how an uploaded file is committed to Dropbox
+type UploadOptions struct {
+	// Mode defaults to WriteModeAdd
+	Mode WriteMode
+	// Rev is the revision the existing file must have for WriteModeUpdate
+	Rev string
+	// Autorename lets Dropbox pick a new name instead of reporting a conflict
+	Autorename bool
+	// Mute suppresses the change notification on the user's devices
+	Mute bool
+}
+
+// commitInfo builds the SDK commit arguments for an upload to path
+func (o UploadOptions) commitInfo(path string) (*files.CommitInfo, error) {
+	commitInfo := files.NewCommitInfo(path)
+	commitInfo.Autorename = o.Autorename
+	commitInfo.Mute = o.Mute
+
+	switch o.Mode {
+	case "", WriteModeAdd:
+	case WriteModeOverwrite:
+		commitInfo.Mode = &files.WriteMode{Tagged: dropbox.Tagged{Tag: files.WriteModeOverwrite}}
+	case WriteModeUpdate:
+		if o.Rev == "" {
+			return nil, fmt.Errorf("update write mode requires a revision")
+		}
+		commitInfo.Mode = &files.WriteMode{Tagged: dropbox.Tagged{Tag: files.WriteModeUpdate}, Update: o.Rev}
+	default:
+		return nil, fmt.Errorf("unknown write mode '%s'", o.Mode)
+	}
+
+	return commitInfo, nil
+}
+
+// UploadFile uploads a local file to Dropbox. Files no larger than the
+// client's chunk size are sent in a single request, anything bigger goes
+// through an upload session one chunk at a time. Every request carries the
+// content hash of its data and the committed file's content hash is checked
+// against the local file, a difference is reported as ErrHashMismatch.
+// A write refused because of the existing destination is reported as a
+// *ConflictError. Cancelling ctx stops the upload after the current chunk
+// request is aborted, the session progress stays saved for resuming.
+func (c *Client) UploadFile(ctx context.Context, localPath, dropboxPath string, opts UploadOptions) error {
+
+	dropboxPath = normalizePath(dropboxPath)
+
+	commitInfo, err := opts.commitInfo(dropboxPath)
+	if err != nil {
+		return err
+	}
+
+	if c.chunkSize > MaxChunkSize {
+		return fmt.Errorf("chunk size %d exceeds the %d byte limit", c.chunkSize, MaxChunkSize)
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open local file '%s': %w", localPath, err)
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get the file info: %w", err)
+	}
+
+	if fileInfo.Size() <= c.chunkSize {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return fmt.Errorf("failed to read local file '%s': %w", localPath, err)
+		}
+
+		// Dropbox rejects the upload if the content it receives has a different hash
+		uploadArg := &files.UploadArg{CommitInfo: *commitInfo, ContentHash: chunkContentHash(data)}
+		var metadata *files.FileMetadata
+		err = c.retry(ctx, func() (err error) {
+			metadata, err = c.files(ctx).Upload(uploadArg, bytes.NewReader(data))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload file '%s': %w", localPath, classify(dropboxPath, err))
+		}
+
+		return verifyContentHash(dropboxPath, uploadArg.ContentHash, metadata)
+	}
+
+	localPath, err = filepath.Abs(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve local path: %w", err)
+	}
+
+	err = c.uploadSession(ctx, file, fileInfo, localPath, commitInfo)
+	if err != nil {
+		return fmt.Errorf("failed to upload file '%s': %w", localPath, classify(dropboxPath, err))
+	}
+
+	return nil
+}
+
+// PendingUpload returns the saved session an upload of localPath to
+// dropboxPath would resume from, or nil if it would start from the beginning.
+func (c *Client) PendingUpload(localPath, dropboxPath string) (*UploadSession, error) {
+	localPath, err := filepath.Abs(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve local path: %w", err)
+	}
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the file info: %w", err)
+	}
+
+	return c.savedSession(localPath, normalizePath(dropboxPath), info)
+}
+
+// uploadSession sends the contents of r through an upload session and
+// commits them with commitInfo. If a session for the same transfer was saved
+// by an earlier, interrupted run it is continued instead of starting over.
+func (c *Client) uploadSession(ctx context.Context, r io.ReaderAt, info os.FileInfo, localPath string, commitInfo *files.CommitInfo) error {
+	session, err := c.savedSession(localPath, commitInfo.Path, info)
+	if err != nil {
+		return err
+	}
+
+	if session != nil {
+		err = c.continueSession(ctx, r, session, commitInfo)
+		if !isSessionGone(err) {
+			return err
+		}
+
+		// Dropbox no longer knows about the saved session, start a new one
+		err = c.forgetSession(session)
+		if err != nil {
+			return err
+		}
+	}
+
+	session, err = c.startSession(ctx, r, info, localPath, commitInfo.Path)
+	if err != nil {
+		return err
+	}
+
+	return c.continueSession(ctx, r, session, commitInfo)
+}
+
+// startSession opens a new upload session with the first chunk of r
+func (c *Client) startSession(ctx context.Context, r io.ReaderAt, info os.FileInfo, localPath, dropboxPath string) (*UploadSession, error) {
+	buf := make([]byte, min(c.chunkSize, info.Size()))
+
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read first chunk: %w", err)
+	}
+
+	startArg := files.NewUploadSessionStartArg()
+	startArg.ContentHash = chunkContentHash(buf[:n])
+
+	var startResult *files.UploadSessionStartResult
+	err = c.retry(ctx, func() (err error) {
+		startResult, err = c.files(ctx).UploadSessionStart(startArg, bytes.NewReader(buf[:n]))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start upload session: %w", err)
+	}
+
+	session := &UploadSession{
+		SessionID:   startResult.SessionId,
+		LocalPath:   localPath,
+		DropboxPath: dropboxPath,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		Offset:      uint64(n),
+		StartedAt:   time.Now(),
+	}
+
+	err = c.saveSession(session)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// continueSession appends the rest of r from the session's offset onwards,
+// recording progress after every chunk, and then commits the file.
+func (c *Client) continueSession(ctx context.Context, r io.ReaderAt, session *UploadSession, commitInfo *files.CommitInfo) error {
+	filesClient := c.files(ctx)
+	buf := make([]byte, c.chunkSize)
+	cursor := files.NewUploadSessionCursor(session.SessionID, session.Offset)
+
+	for session.Size-int64(cursor.Offset) > c.chunkSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := r.ReadAt(buf, int64(cursor.Offset))
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read chunk at offset %d: %w", cursor.Offset, err)
+		}
+
+		appendArg := files.NewUploadSessionAppendArg(cursor)
+		appendArg.ContentHash = chunkContentHash(buf[:n])
+		err = c.retry(ctx, func() error {
+			return filesClient.UploadSessionAppendV2(appendArg, bytes.NewReader(buf[:n]))
+		})
+		if offset, ok := correctOffset(err); ok {
+			// Dropbox received a different amount than we recorded, carry on from its offset
+			cursor.Offset = offset
+			session.Offset = offset
+
+			err = c.saveSession(session)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to append chunk at offset %d: %w", cursor.Offset, err)
+		}
+
+		cursor.Offset += uint64(n)
+		session.Offset = cursor.Offset
+
+		err = c.saveSession(session)
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := r.ReadAt(buf[:session.Size-int64(cursor.Offset)], int64(cursor.Offset))
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read final chunk: %w", err)
+	}
+
+	finishArg := files.NewUploadSessionFinishArg(cursor, commitInfo)
+	finishArg.ContentHash = chunkContentHash(buf[:n])
+	var metadata *files.FileMetadata
+	attempts := 0
+	err = c.retry(ctx, func() (err error) {
+		attempts++
+		metadata, err = filesClient.UploadSessionFinish(finishArg, bytes.NewReader(buf[:n]))
+		return err
+	})
+	var finishErr files.UploadSessionFinishAPIError
+	if attempts > 1 && errors.As(err, &finishErr) && mayHaveCommitted(finishErr.EndpointError) {
+		// An earlier attempt may have been committed with its response lost
+		expected, hashErr := ContentHash(io.NewSectionReader(r, 0, session.Size))
+		if hashErr != nil {
+			return fmt.Errorf("failed to hash local file: %w", hashErr)
+		}
+		if c.committedEarlier(ctx, commitInfo.Path, expected) {
+			return c.forgetSession(session)
+		}
+	}
+	if errors.Is(classify(commitInfo.Path, err), ErrConflict) {
+		// The final chunk was accepted before the commit was refused, so the
+		// recorded offset is stale and a retry has to start a new session
+		forgetErr := c.forgetSession(session)
+		if forgetErr != nil {
+			return forgetErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to finish upload session: %w", err)
+	}
+
+	err = c.forgetSession(session)
+	if err != nil {
+		return err
+	}
+
+	// The chunks were checked one by one, this catches chunks that were
+	// lost or appended twice across interrupted runs
+	expected, err := ContentHash(io.NewSectionReader(r, 0, session.Size))
+	if err != nil {
+		return fmt.Errorf("failed to hash local file: %w", err)
+	}
+
+	return verifyContentHash(commitInfo.Path, expected, metadata)
+}
+
+// savedSession looks up a resumable session for the transfer, ignoring
+// sessions recorded for a different version of the local file.
+func (c *Client) savedSession(localPath, dropboxPath string, info os.FileInfo) (*UploadSession, error) {
+	if c.sessions == nil {
+		return nil, nil
+	}
+
+	session, err := c.sessions.Get(localPath, dropboxPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load upload session: %w", err)
+	}
+
+	if session == nil || !session.matches(info) {
+		return nil, nil
+	}
+
+	return session, nil
+}
+
+func (c *Client) saveSession(session *UploadSession) error {
+	if c.sessions == nil {
+		return nil
+	}
+
+	err := c.sessions.Put(session)
+	if err != nil {
+		return fmt.Errorf("failed to save upload session: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) forgetSession(session *UploadSession) error {
+	if c.sessions == nil {
+		return nil
+	}
+
+	err := c.sessions.Remove(session.LocalPath, session.DropboxPath)
+	if err != nil {
+		return fmt.Errorf("failed to remove upload session: %w", err)
+	}
+
+	return nil
+}
+
+// correctOffset extracts the offset Dropbox expects from an incorrect_offset append error
+func correctOffset(err error) (uint64, bool) {
+	var appendErr files.UploadSessionAppendV2APIError
+	if !errors.As(err, &appendErr) || appendErr.EndpointError == nil {
+		return 0, false
+	}
+
+	if appendErr.EndpointError.Tag != files.UploadSessionAppendErrorIncorrectOffset || appendErr.EndpointError.IncorrectOffset == nil {
+		return 0, false
+	}
+
+	return appendErr.EndpointError.IncorrectOffset.CorrectOffset, true
+}
+
+// mayHaveCommitted reports whether a retried finish could have been refused
+// because an earlier attempt of the same commit succeeded: the session is
+// then gone or the destination is taken
+func mayHaveCommitted(finishErr *files.UploadSessionFinishError) bool {
+	if finishErr == nil {
+		return false
+	}
+
+	switch finishErr.Tag {
+	case files.UploadSessionFinishErrorLookupFailed:
+		return true
+	case files.UploadSessionFinishErrorPath:
+		return finishErr.Path != nil && finishErr.Path.Tag == files.WriteErrorConflict
+	}
+
+	return false
+}
+
+// committedEarlier reports whether the file at dropboxPath has contentHash,
+// meaning a commit that looked like it failed went through after all
+func (c *Client) committedEarlier(ctx context.Context, dropboxPath, contentHash string) bool {
+	info, err := c.GetFileInfo(ctx, dropboxPath)
+	return err == nil && !info.IsFolder && info.ContentHash == contentHash
+}
+
+// isSessionGone reports whether err means the upload session can no longer be used
+func isSessionGone(err error) bool {
+	var tag string
+
+	var appendErr files.UploadSessionAppendV2APIError
+	var finishErr files.UploadSessionFinishAPIError
+	switch {
+	case errors.As(err, &appendErr) && appendErr.EndpointError != nil:
+		tag = appendErr.EndpointError.Tag
+	case errors.As(err, &finishErr) && finishErr.EndpointError != nil && finishErr.EndpointError.LookupFailed != nil:
+		tag = finishErr.EndpointError.LookupFailed.Tag
+	}
+
+	switch tag {
+	case files.UploadSessionLookupErrorNotFound, files.UploadSessionLookupErrorClosed:
+		return true
+	}
+
+	// Only finish can still report a bad offset here, append errors of that
+	// kind are corrected in continueSession
+	return tag == files.UploadSessionLookupErrorIncorrectOffset
+}
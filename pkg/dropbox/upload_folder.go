@@ -0,0 +1,400 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+const (
+	// DefaultUploadWorkers is the number of files UploadFolder uploads at once when none is configured
+	DefaultUploadWorkers = 4
+
+	// MaxBatchSize is the most files Dropbox commits in a single finish batch call
+	MaxBatchSize = 1000
+)
+
+// FolderUploadOptions controls how UploadFolder uploads a directory tree
+type FolderUploadOptions struct {
+	UploadOptions
+	// Workers is the number of files uploaded at the same time
+	Workers int
+	// BatchSize is the number of small files committed together, at most MaxBatchSize
+	BatchSize int
+}
+
+func (o FolderUploadOptions) workers() int {
+	if o.Workers <= 0 {
+		return DefaultUploadWorkers
+	}
+	return o.Workers
+}
+
+func (o FolderUploadOptions) batchSize() int {
+	if o.BatchSize <= 0 || o.BatchSize > MaxBatchSize {
+		return MaxBatchSize
+	}
+	return o.BatchSize
+}
+
+// UploadStatus is the outcome of uploading one file of a folder
+type UploadStatus int
+
+const (
+	UploadStatusUploaded UploadStatus = iota
+	UploadStatusSkipped
+	UploadStatusFailed
+)
+
+// UploadSummary counts the outcomes of an UploadFolder call
+type UploadSummary struct {
+	Uploaded int
+	Skipped  int
+	// Failed maps the local path of every file that could not be uploaded to its error
+	Failed map[string]error
+}
+
+// UploadFolder uploads the directory tree at localDir into the folder at
+// dropboxPath, creating any missing remote folders. Files are uploaded by
+// opts.Workers concurrent workers. Files that fit in one chunk are committed
+// together in batches, larger ones go through UploadFile. Files that already
+// exist remotely with the same size and content hash are skipped, as is
+// anything that is not a regular file. If progress is not nil it is called
+// once per file. Failed files are recorded in the summary and do not stop
+// the upload, the returned error only reports problems walking the trees
+// and the cancellation of ctx. The summary of the files handled so far is
+// returned along with an error that stopped the walk.
+func (c *Client) UploadFolder(ctx context.Context, localDir, dropboxPath string, opts FolderUploadOptions, progress func(localPath string, status UploadStatus, err error)) (*UploadSummary, error) {
+
+	dropboxPath = normalizePath(dropboxPath)
+
+	remote, err := c.remoteIndex(ctx, dropboxPath)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &folderUpload{
+		ctx:      ctx,
+		client:   c,
+		opts:     opts,
+		remote:   remote,
+		progress: progress,
+		summary:  UploadSummary{Failed: make(map[string]error)},
+	}
+
+	jobs := make(chan folderUploadJob)
+	var wg sync.WaitGroup
+
+	for i := 0; i < opts.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				u.upload(job)
+			}
+		}()
+	}
+
+	err = filepath.WalkDir(localDir, func(localPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(localDir, localPath)
+		if err != nil {
+			return err
+		}
+		target := path.Join("/", dropboxPath, filepath.ToSlash(rel))
+
+		switch {
+		case entry.IsDir():
+			return u.ensureFolder(target)
+		case entry.Type().IsRegular():
+			jobs <- folderUploadJob{localPath: localPath, dropboxPath: target}
+		default:
+			u.report(localPath, UploadStatusSkipped, nil)
+		}
+		return nil
+	})
+
+	close(jobs)
+	wg.Wait()
+
+	// Files already sent in closed sessions are committed even if the walk
+	// failed. With ctx cancelled the commit fails and they are reported as failed.
+	u.mu.Lock()
+	pending := u.batch
+	u.batch = nil
+	u.mu.Unlock()
+	u.commit(pending)
+
+	if err != nil {
+		return &u.summary, fmt.Errorf("failed to walk local directory '%s': %w", localDir, err)
+	}
+
+	return &u.summary, nil
+}
+
+// remoteIndex lists everything below dropboxPath keyed by lower case path.
+// A missing folder is treated as empty.
+func (c *Client) remoteIndex(ctx context.Context, dropboxPath string) (map[string]FileInfo, error) {
+	index := make(map[string]FileInfo)
+
+	entries, err := c.listFolder(ctx, dropboxPath, true)
+	if errors.Is(err, ErrNotFound) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		index[entry.Path] = entry
+	}
+
+	return index, nil
+}
+
+type folderUploadJob struct {
+	localPath   string
+	dropboxPath string
+}
+
+// batchEntry is a small file whose content has been sent in a closed
+// upload session and that is waiting to be committed
+type batchEntry struct {
+	localPath   string
+	contentHash string
+	finishArg   *files.UploadSessionFinishArg
+}
+
+// folderUpload holds the shared state of an UploadFolder call
+type folderUpload struct {
+	ctx      context.Context
+	client   *Client
+	opts     FolderUploadOptions
+	remote   map[string]FileInfo
+	progress func(localPath string, status UploadStatus, err error)
+
+	mu      sync.Mutex
+	summary UploadSummary
+	batch   []batchEntry
+
+	// commitMu serializes batch commits, concurrent commits into the same
+	// namespace would only contend for the same lock on the server
+	commitMu sync.Mutex
+}
+
+func (u *folderUpload) report(localPath string, status UploadStatus, err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	switch status {
+	case UploadStatusUploaded:
+		u.summary.Uploaded++
+	case UploadStatusSkipped:
+		u.summary.Skipped++
+	case UploadStatusFailed:
+		u.summary.Failed[localPath] = err
+	}
+
+	if u.progress != nil {
+		u.progress(localPath, status, err)
+	}
+}
+
+// ensureFolder creates the remote folder unless it already exists
+func (u *folderUpload) ensureFolder(dropboxPath string) error {
+	if dropboxPath == "/" {
+		return nil
+	}
+
+	if existing, ok := u.remote[strings.ToLower(dropboxPath)]; ok && existing.IsFolder {
+		return nil
+	}
+
+	err := u.client.CreateFolder(u.ctx, dropboxPath)
+	var conflict *ConflictError
+	if errors.As(err, &conflict) && conflict.Reason == files.WriteConflictErrorFolder {
+		return nil
+	}
+
+	return err
+}
+
+func (u *folderUpload) upload(job folderUploadJob) {
+	if err := u.ctx.Err(); err != nil {
+		u.report(job.localPath, UploadStatusFailed, err)
+		return
+	}
+
+	info, err := os.Stat(job.localPath)
+	if err != nil {
+		u.report(job.localPath, UploadStatusFailed, err)
+		return
+	}
+
+	if existing, ok := u.remote[strings.ToLower(job.dropboxPath)]; ok && !existing.IsFolder {
+		unchanged, err := IsLocalCopy(job.localPath, &existing)
+		if err != nil {
+			u.report(job.localPath, UploadStatusFailed, err)
+			return
+		}
+		if unchanged {
+			u.report(job.localPath, UploadStatusSkipped, nil)
+			return
+		}
+	}
+
+	if info.Size() > u.client.chunkSize {
+		err = u.client.UploadFile(u.ctx, job.localPath, job.dropboxPath, u.opts.UploadOptions)
+		if err != nil {
+			u.report(job.localPath, UploadStatusFailed, err)
+			return
+		}
+		u.report(job.localPath, UploadStatusUploaded, nil)
+		return
+	}
+
+	entry, err := u.client.closedSession(u.ctx, job.localPath, job.dropboxPath, u.opts.UploadOptions)
+	if err != nil {
+		u.report(job.localPath, UploadStatusFailed, err)
+		return
+	}
+
+	u.mu.Lock()
+	u.batch = append(u.batch, *entry)
+	var full []batchEntry
+	if len(u.batch) >= u.opts.batchSize() {
+		full = u.batch
+		u.batch = nil
+	}
+	u.mu.Unlock()
+
+	u.commit(full)
+}
+
+// commit finishes a batch of closed upload sessions in one request.
+// Entries refused because of concurrent writes are committed again.
+func (u *folderUpload) commit(entries []batchEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	u.commitMu.Lock()
+	defer u.commitMu.Unlock()
+
+	attempts := 0
+	err := u.client.retry(u.ctx, func() (err error) {
+		attempts++
+		entries, err = u.finishBatch(entries, attempts > 1)
+		if err == nil && len(entries) > 0 {
+			return errTooManyWriteOperations
+		}
+		return err
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to commit upload batch: %w", classify("", err))
+		for _, entry := range entries {
+			u.report(entry.localPath, UploadStatusFailed, err)
+		}
+	}
+}
+
+// finishBatch commits entries and reports their results. It returns the
+// entries that were refused with too_many_write_operations, or all of them
+// if the request failed. When retried is set, entries refused because an
+// earlier attempt may have committed them are checked on Dropbox.
+func (u *folderUpload) finishBatch(entries []batchEntry, retried bool) ([]batchEntry, error) {
+	finishArgs := make([]*files.UploadSessionFinishArg, len(entries))
+	for i, entry := range entries {
+		finishArgs[i] = entry.finishArg
+	}
+
+	result, err := u.client.files(u.ctx).UploadSessionFinishBatchV2(files.NewUploadSessionFinishBatchArg(finishArgs))
+	if err != nil {
+		return entries, err
+	}
+
+	var refused []batchEntry
+	for i, entry := range entries {
+		if i >= len(result.Entries) {
+			u.report(entry.localPath, UploadStatusFailed, fmt.Errorf("no result for '%s' in upload batch", entry.localPath))
+			continue
+		}
+
+		resultEntry := result.Entries[i]
+		if resultEntry.Tag == files.UploadSessionFinishBatchResultEntrySuccess && resultEntry.Success != nil {
+			err = verifyContentHash(entry.finishArg.Commit.Path, entry.contentHash, resultEntry.Success)
+			if err != nil {
+				u.report(entry.localPath, UploadStatusFailed, err)
+				continue
+			}
+			u.report(entry.localPath, UploadStatusUploaded, nil)
+			continue
+		}
+
+		err = fmt.Errorf("failed to commit '%s': %s", entry.finishArg.Commit.Path, resultEntry.Tag)
+		if failure := resultEntry.Failure; failure != nil {
+			if failure.Tag == files.UploadSessionFinishErrorTooManyWriteOperations {
+				refused = append(refused, entry)
+				continue
+			}
+			if retried && mayHaveCommitted(failure) && u.client.committedEarlier(u.ctx, entry.finishArg.Commit.Path, entry.contentHash) {
+				u.report(entry.localPath, UploadStatusUploaded, nil)
+				continue
+			}
+			err = fmt.Errorf("failed to commit '%s': %s", entry.finishArg.Commit.Path, failure.Tag)
+			err = fromWriteError(entry.finishArg.Commit.Path, failure.Path, err)
+		}
+		u.report(entry.localPath, UploadStatusFailed, err)
+	}
+
+	return refused, nil
+}
+
+// closedSession uploads a file that fits in one chunk into a new, closed
+// upload session and returns the batch entry needed to commit it.
+func (c *Client) closedSession(ctx context.Context, localPath, dropboxPath string, opts UploadOptions) (*batchEntry, error) {
+	commitInfo, err := opts.commitInfo(dropboxPath)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local file '%s': %w", localPath, err)
+	}
+
+	startArg := files.NewUploadSessionStartArg()
+	startArg.Close = true
+	startArg.ContentHash = chunkContentHash(data)
+
+	var startResult *files.UploadSessionStartResult
+	err = c.retry(ctx, func() (err error) {
+		startResult, err = c.files(ctx).UploadSessionStart(startArg, bytes.NewReader(data))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload file '%s': %w", localPath, classify(dropboxPath, err))
+	}
+
+	cursor := files.NewUploadSessionCursor(startResult.SessionId, uint64(len(data)))
+	return &batchEntry{
+		localPath:   localPath,
+		contentHash: startArg.ContentHash,
+		finishArg:   files.NewUploadSessionFinishArg(cursor, commitInfo),
+	}, nil
+}
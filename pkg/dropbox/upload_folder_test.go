@@ -0,0 +1,169 @@
+package dropbox_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"valboks/pkg/dropbox"
+	"valboks/pkg/dropbox/dropboxtest"
+)
+
+// writeLocalTree creates files below dir from a map of slash separated paths to contents
+func writeLocalTree(t *testing.T, dir string, tree map[string]string) {
+	t.Helper()
+
+	for name, content := range tree {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUploadFolderSummary(t *testing.T) {
+	local := map[string]string{
+		"a.txt":         "a",
+		"sub/b.txt":     "b",
+		"sub/deep/big":  "larger than one chunk",
+		"unchanged.txt": "same",
+		"changed.txt":   "new",
+	}
+
+	tests := []struct {
+		name     string
+		opts     dropbox.FolderUploadOptions
+		fault    func(*dropboxtest.Server)
+		uploaded int
+		skipped  int
+		failed   []string
+	}{
+		{
+			name:     "add",
+			uploaded: 3,
+			skipped:  2,
+			failed:   []string{"changed.txt"},
+		},
+		{
+			name:     "overwrite",
+			opts:     dropbox.FolderUploadOptions{UploadOptions: dropbox.UploadOptions{Mode: dropbox.WriteModeOverwrite}},
+			uploaded: 4,
+			skipped:  2,
+		},
+		{
+			name: "too many write operations is retried",
+			opts: dropbox.FolderUploadOptions{UploadOptions: dropbox.UploadOptions{Mode: dropbox.WriteModeOverwrite}},
+			fault: func(s *dropboxtest.Server) {
+				s.InjectFault("files/upload_session/finish_batch_v2", 1, dropboxtest.TooManyWriteOperations())
+			},
+			uploaded: 4,
+			skipped:  2,
+		},
+		{
+			name: "failed batch",
+			opts: dropbox.FolderUploadOptions{UploadOptions: dropbox.UploadOptions{Mode: dropbox.WriteModeOverwrite}},
+			fault: func(s *dropboxtest.Server) {
+				s.InjectFault("files/upload_session/finish_batch_v2", 3, dropboxtest.ServerError(http.StatusInternalServerError))
+			},
+			uploaded: 1,
+			skipped:  2,
+			failed:   []string{"a.txt", "sub/b.txt", "changed.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestClient(t, nil, dropbox.WithChunkSize(8))
+			putFile(t, server, "/dst/unchanged.txt", []byte("same"))
+			putFile(t, server, "/dst/changed.txt", []byte("old"))
+			if tt.fault != nil {
+				tt.fault(server)
+			}
+
+			dir := t.TempDir()
+			writeLocalTree(t, dir, local)
+			if err := os.Symlink("a.txt", filepath.Join(dir, "link")); err != nil {
+				t.Fatal(err)
+			}
+
+			var mu sync.Mutex
+			reported := 0
+			summary, err := client.UploadFolder(context.Background(), dir, "/dst", tt.opts, func(string, dropbox.UploadStatus, error) {
+				mu.Lock()
+				reported++
+				mu.Unlock()
+			})
+			if err != nil {
+				t.Fatalf("UploadFolder: %v", err)
+			}
+
+			if summary.Uploaded != tt.uploaded || summary.Skipped != tt.skipped || len(summary.Failed) != len(tt.failed) {
+				t.Errorf("summary = %d uploaded, %d skipped, %d failed (%v), want %d, %d, %d",
+					summary.Uploaded, summary.Skipped, len(summary.Failed), summary.Failed,
+					tt.uploaded, tt.skipped, len(tt.failed))
+			}
+			for _, name := range tt.failed {
+				if _, ok := summary.Failed[filepath.Join(dir, filepath.FromSlash(name))]; !ok {
+					t.Errorf("%s is not reported as failed", name)
+				}
+			}
+			if want := len(local) + 1; reported != want {
+				t.Errorf("progress was called %d times, want %d", reported, want)
+			}
+
+			if content, ok := server.ReadFile("/dst/sub/deep/big"); !ok || string(content) != local["sub/deep/big"] {
+				t.Errorf("/dst/sub/deep/big = %q, %v, want the local content", content, ok)
+			}
+		})
+	}
+}
+
+func TestUploadFolderCommitsBatchWhenWalkFails(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	if err := server.Mkdir("/dst"); err != nil {
+		t.Fatal(err)
+	}
+	server.InjectFault("files/create_folder_v2", 3, dropboxtest.ServerError(http.StatusInternalServerError))
+
+	dir := t.TempDir()
+	writeLocalTree(t, dir, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+
+	summary, err := client.UploadFolder(context.Background(), dir, "/dst", dropbox.FolderUploadOptions{}, nil)
+	if err == nil {
+		t.Fatal("UploadFolder succeeded although /dst/sub could not be created")
+	}
+	if summary == nil {
+		t.Fatal("UploadFolder returned no summary with the walk error")
+	}
+	if summary.Uploaded != 1 || len(summary.Failed) != 0 {
+		t.Errorf("summary = %d uploaded, %d failed, want 1 uploaded", summary.Uploaded, len(summary.Failed))
+	}
+	if content, ok := server.ReadFile("/dst/a.txt"); !ok || string(content) != "a" {
+		t.Errorf("/dst/a.txt = %q, %v, want the file batched before the walk failed", content, ok)
+	}
+}
+
+func TestUploadFolderBatchResponseLost(t *testing.T) {
+	server, client := newTestClient(t, nil)
+	server.InjectFault("files/upload_session/finish_batch_v2", 1, dropboxtest.LostResponse())
+
+	dir := t.TempDir()
+	writeLocalTree(t, dir, map[string]string{"a.txt": "a", "b.txt": "b"})
+
+	summary, err := client.UploadFolder(context.Background(), dir, "/dst", dropbox.FolderUploadOptions{}, nil)
+	if err != nil {
+		t.Fatalf("UploadFolder: %v", err)
+	}
+	if summary.Uploaded != 2 || len(summary.Failed) != 0 {
+		t.Errorf("summary = %d uploaded, %d failed (%v), want 2 uploaded", summary.Uploaded, len(summary.Failed), summary.Failed)
+	}
+	if got := server.Requests("files/upload_session/finish_batch_v2"); got != 2 {
+		t.Errorf("finish_batch_v2 requests = %d, want 2", got)
+	}
+}
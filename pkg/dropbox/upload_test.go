@@ -0,0 +1,254 @@
+package dropbox_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"valboks/pkg/dropbox"
+	"valboks/pkg/dropbox/dropboxtest"
+)
+
+// memorySessionStore keeps upload sessions in memory and records every Put
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[[2]string]dropbox.UploadSession
+	puts     []dropbox.UploadSession
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[[2]string]dropbox.UploadSession)}
+}
+
+func (s *memorySessionStore) Get(localPath, dropboxPath string) (*dropbox.UploadSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[[2]string{localPath, dropboxPath}]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (s *memorySessionStore) Put(session *dropbox.UploadSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[[2]string{session.LocalPath, session.DropboxPath}] = *session
+	s.puts = append(s.puts, *session)
+	return nil
+}
+
+func (s *memorySessionStore) Remove(localPath, dropboxPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, [2]string{localPath, dropboxPath})
+	return nil
+}
+
+// only returns the single stored session, failing the test if there is not exactly one
+func (s *memorySessionStore) only(t *testing.T) dropbox.UploadSession {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.sessions) != 1 {
+		t.Fatalf("store holds %d sessions, want 1", len(s.sessions))
+	}
+	for _, session := range s.sessions {
+		return session
+	}
+	panic("unreachable")
+}
+
+// writeLocalFile writes size bytes of a repeating pattern to a temporary file
+func writeLocalFile(t *testing.T, name string, size int) (string, []byte) {
+	t.Helper()
+
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte('a' + i%26)
+	}
+
+	localPath := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(localPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return localPath, data
+}
+
+func TestUploadResumesFromCorrectedOffset(t *testing.T) {
+	store := newMemorySessionStore()
+	server, client := newTestClient(t, nil, dropbox.WithChunkSize(4), dropbox.WithSessionStore(store))
+	localPath, data := writeLocalFile(t, "file.bin", 13)
+
+	// The first run starts the session with 4 bytes and then fails to append
+	server.InjectFault("files/upload_session/append_v2", 3, dropboxtest.ServerError(http.StatusInternalServerError))
+	if err := client.UploadFile(context.Background(), localPath, "/file.bin", dropbox.UploadOptions{}); err == nil {
+		t.Fatal("first UploadFile succeeded despite the injected faults")
+	}
+
+	// Pretend the saved progress lags behind what Dropbox received
+	session := store.only(t)
+	if session.Offset != 4 {
+		t.Fatalf("saved offset = %d, want 4", session.Offset)
+	}
+	session.Offset = 0
+	store.Put(&session)
+	store.puts = nil
+
+	if err := client.UploadFile(context.Background(), localPath, "/file.bin", dropbox.UploadOptions{}); err != nil {
+		t.Fatalf("resumed UploadFile: %v", err)
+	}
+
+	if len(store.puts) == 0 || store.puts[0].Offset != 4 {
+		t.Errorf("saved offsets after resuming = %v, want the corrected offset 4 first", offsets(store.puts))
+	}
+	if content, _ := server.ReadFile("/file.bin"); string(content) != string(data) {
+		t.Errorf("uploaded content = %q, want %q", content, data)
+	}
+}
+
+func offsets(sessions []dropbox.UploadSession) []uint64 {
+	result := make([]uint64, len(sessions))
+	for i, session := range sessions {
+		result[i] = session.Offset
+	}
+	return result
+}
+
+func TestUploadChunkBoundary(t *testing.T) {
+	const chunkSize = 8
+
+	tests := []struct {
+		name     string
+		size     int
+		uploads  int
+		sessions int
+	}{
+		{"empty", 0, 1, 0},
+		{"exactly one chunk", chunkSize, 1, 0},
+		{"one byte over", chunkSize + 1, 0, 1},
+		{"several chunks", 3*chunkSize + 1, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestClient(t, nil, dropbox.WithChunkSize(chunkSize))
+			localPath, data := writeLocalFile(t, "file.bin", tt.size)
+
+			if err := client.UploadFile(context.Background(), localPath, "/file.bin", dropbox.UploadOptions{}); err != nil {
+				t.Fatalf("UploadFile: %v", err)
+			}
+
+			if got := server.Requests("files/upload"); got != tt.uploads {
+				t.Errorf("upload requests = %d, want %d", got, tt.uploads)
+			}
+			if got := server.Requests("files/upload_session/start"); got != tt.sessions {
+				t.Errorf("upload_session/start requests = %d, want %d", got, tt.sessions)
+			}
+			if got := server.Requests("files/upload_session/finish"); got != tt.sessions {
+				t.Errorf("upload_session/finish requests = %d, want %d", got, tt.sessions)
+			}
+			if content, ok := server.ReadFile("/file.bin"); !ok || string(content) != string(data) {
+				t.Errorf("uploaded content = %q, want %q", content, data)
+			}
+		})
+	}
+}
+
+func TestUploadResumesSavedSession(t *testing.T) {
+	store := dropbox.NewFileSessionStore(filepath.Join(t.TempDir(), "sessions.json"))
+	server, client := newTestClient(t, nil, dropbox.WithChunkSize(4), dropbox.WithSessionStore(store))
+	localPath, data := writeLocalFile(t, "file.bin", 13)
+
+	// Interrupt the upload after the second chunk was appended
+	server.InjectFault("files/upload_session/finish", 3, dropboxtest.ServerError(http.StatusInternalServerError))
+	if err := client.UploadFile(context.Background(), localPath, "/file.bin", dropbox.UploadOptions{}); err == nil {
+		t.Fatal("first UploadFile succeeded despite the injected faults")
+	}
+
+	pending, err := client.PendingUpload(localPath, "/file.bin")
+	if err != nil {
+		t.Fatalf("PendingUpload: %v", err)
+	}
+	if pending == nil || pending.Offset != 12 {
+		t.Fatalf("pending session = %+v, want one at offset 12", pending)
+	}
+
+	appended := server.Requests("files/upload_session/append_v2")
+	if err := client.UploadFile(context.Background(), localPath, "/file.bin", dropbox.UploadOptions{}); err != nil {
+		t.Fatalf("resumed UploadFile: %v", err)
+	}
+
+	if got := server.Requests("files/upload_session/start"); got != 1 {
+		t.Errorf("upload_session/start requests = %d, want 1", got)
+	}
+	if got := server.Requests("files/upload_session/append_v2"); got != appended {
+		t.Errorf("resuming appended %d more chunks, want none", got-appended)
+	}
+	if content, _ := server.ReadFile("/file.bin"); string(content) != string(data) {
+		t.Errorf("uploaded content = %q, want %q", content, data)
+	}
+
+	pending, err = client.PendingUpload(localPath, "/file.bin")
+	if err != nil {
+		t.Fatalf("PendingUpload after commit: %v", err)
+	}
+	if pending != nil {
+		t.Errorf("session %+v is still saved after the upload was committed", pending)
+	}
+}
+
+func TestUploadRetriedFinish(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []byte
+		fault    dropboxtest.Fault
+		wantErr  error
+	}{
+		{"response of the commit lost", nil, dropboxtest.LostResponse(), nil},
+		{"destination taken by another file", []byte("other"), dropboxtest.ServerError(http.StatusInternalServerError), dropbox.ErrConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemorySessionStore()
+			server, client := newTestClient(t, nil, dropbox.WithChunkSize(4), dropbox.WithSessionStore(store))
+			localPath, data := writeLocalFile(t, "file.bin", 13)
+			if tt.existing != nil {
+				putFile(t, server, "/file.bin", tt.existing)
+			}
+			server.InjectFault("files/upload_session/finish", 1, tt.fault)
+
+			err := client.UploadFile(context.Background(), localPath, "/file.bin", dropbox.UploadOptions{})
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("UploadFile: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UploadFile error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := server.Requests("files/upload_session/finish"); got != 2 {
+				t.Errorf("upload_session/finish requests = %d, want 2", got)
+			}
+			if len(store.sessions) != 0 {
+				t.Errorf("store holds %d sessions after the commit, want none", len(store.sessions))
+			}
+			want := data
+			if tt.existing != nil {
+				want = tt.existing
+			}
+			if content, _ := server.ReadFile("/file.bin"); string(content) != string(want) {
+				t.Errorf("content on Dropbox = %q, want %q", content, want)
+			}
+		})
+	}
+}
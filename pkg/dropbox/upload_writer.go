@@ -0,0 +1,156 @@
+package dropbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+
+	"github.com/dropbox/dropbox-sdk-go-unofficial/v6/dropbox/files"
+)
+
+// ErrWriterClosed is returned when writing to an UploadWriter after Close or Abort
+var ErrWriterClosed = errors.New("upload writer is closed")
+
+// UploadWriter streams data written to it into a file on Dropbox. Data is
+// sent one chunk at a time through an upload session, so content of any
+// size and unknown length can be uploaded. The file is only committed by
+// Close, nothing is created if the writer is aborted.
+type UploadWriter struct {
+	ctx        context.Context
+	client     *Client
+	commitInfo *files.CommitInfo
+	buf        []byte
+	hash       hash.Hash
+	// cursor is nil until the first chunk has started a session
+	cursor *files.UploadSessionCursor
+	closed bool
+}
+
+// CreateFile returns a writer that uploads to dropboxPath, committed with
+// opts when the writer is closed
+func (c *Client) CreateFile(ctx context.Context, dropboxPath string, opts UploadOptions) (*UploadWriter, error) {
+	dropboxPath = normalizePath(dropboxPath)
+
+	commitInfo, err := opts.commitInfo(dropboxPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.chunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("chunk size %d exceeds the %d byte limit", c.chunkSize, MaxChunkSize)
+	}
+
+	return &UploadWriter{
+		ctx:        ctx,
+		client:     c,
+		commitInfo: commitInfo,
+		hash:       NewContentHash(),
+	}, nil
+}
+
+// Write buffers p and sends every full chunk. The last chunk is held back
+// so that it can be sent with the commit.
+func (w *UploadWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+
+	w.buf = append(w.buf, p...)
+	w.hash.Write(p)
+
+	for int64(len(w.buf)) > w.client.chunkSize {
+		err := w.sendChunk(w.buf[:w.client.chunkSize])
+		if err != nil {
+			return 0, fmt.Errorf("failed to upload file '%s': %w", w.commitInfo.Path, classify(w.commitInfo.Path, err))
+		}
+		w.buf = w.buf[w.client.chunkSize:]
+	}
+
+	return len(p), nil
+}
+
+// sendChunk starts the upload session with chunk or appends chunk to it
+func (w *UploadWriter) sendChunk(chunk []byte) error {
+	c := w.client
+	contentHash := chunkContentHash(chunk)
+
+	if w.cursor == nil {
+		startArg := files.NewUploadSessionStartArg()
+		startArg.ContentHash = contentHash
+
+		var startResult *files.UploadSessionStartResult
+		err := c.retry(w.ctx, func() (err error) {
+			startResult, err = c.files(w.ctx).UploadSessionStart(startArg, bytes.NewReader(chunk))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start upload session: %w", err)
+		}
+
+		w.cursor = files.NewUploadSessionCursor(startResult.SessionId, uint64(len(chunk)))
+		return nil
+	}
+
+	appendArg := files.NewUploadSessionAppendArg(w.cursor)
+	appendArg.ContentHash = contentHash
+	err := c.retry(w.ctx, func() error {
+		return c.files(w.ctx).UploadSessionAppendV2(appendArg, bytes.NewReader(chunk))
+	})
+	if offset, ok := correctOffset(err); ok && offset == w.cursor.Offset+uint64(len(chunk)) {
+		// A retried request was already received the first time
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to append chunk at offset %d: %w", w.cursor.Offset, err)
+	}
+
+	w.cursor.Offset += uint64(len(chunk))
+	return nil
+}
+
+// Close sends the remaining data, commits the file and checks that Dropbox
+// received the same content that was written
+func (w *UploadWriter) Close() error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	w.closed = true
+
+	c := w.client
+	path := w.commitInfo.Path
+	data := w.buf
+	w.buf = nil
+
+	var metadata *files.FileMetadata
+	var err error
+	if w.cursor == nil {
+		uploadArg := &files.UploadArg{CommitInfo: *w.commitInfo, ContentHash: chunkContentHash(data)}
+		err = c.retry(w.ctx, func() (err error) {
+			metadata, err = c.files(w.ctx).Upload(uploadArg, bytes.NewReader(data))
+			return err
+		})
+	} else {
+		finishArg := files.NewUploadSessionFinishArg(w.cursor, w.commitInfo)
+		finishArg.ContentHash = chunkContentHash(data)
+		err = c.retry(w.ctx, func() (err error) {
+			metadata, err = c.files(w.ctx).UploadSessionFinish(finishArg, bytes.NewReader(data))
+			return err
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload file '%s': %w", path, classify(path, err))
+	}
+
+	return verifyContentHash(path, hex.EncodeToString(w.hash.Sum(nil)), metadata)
+}
+
+// Abort discards the written data without creating the file. An upload
+// session that was already started expires on its own.
+func (w *UploadWriter) Abort() error {
+	w.closed = true
+	w.buf = nil
+	return nil
+}
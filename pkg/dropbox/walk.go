@@ -0,0 +1,84 @@
+package dropbox
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+)
+
+// WalkFunc is called by Walk for every file and folder with its path as
+// Dropbox displays it. Like fs.WalkDirFunc, returning fs.SkipDir for a
+// folder skips its contents, returning it for a file skips the rest of the
+// file's folder and returning fs.SkipAll ends the walk. When root cannot be
+// looked up or listing fails, fn is called with info nil and the error.
+type WalkFunc func(path string, info *FileInfo, err error) error
+
+// Walk calls fn for root and everything below it. Entries are streamed from
+// a single recursive listing, so fn sees them as the pages arrive. Unlike
+// filepath.WalkDir the entries are not sorted, they come in the order
+// Dropbox lists them, which puts every folder before its contents.
+func (c *Client) Walk(ctx context.Context, root string, fn WalkFunc) error {
+	root = normalizePath(root)
+
+	rootInfo := &FileInfo{Path: "/", PathDisplay: "/", IsFolder: true}
+	if root != "" {
+		var err error
+		rootInfo, err = c.GetFileInfo(ctx, root)
+		if err != nil {
+			return walkResult(fn(root, nil, err))
+		}
+	}
+
+	err := fn(rootInfo.PathDisplay, rootInfo, nil)
+	if err != nil || !rootInfo.IsFolder {
+		return walkResult(err)
+	}
+
+	// skipped holds the lower cased paths of folders whose contents are skipped
+	skipped := make(map[string]bool)
+
+	for info, err := range c.ListFolderIter(ctx, root, true) {
+		if err != nil {
+			return walkResult(fn(rootInfo.PathDisplay, nil, err))
+		}
+		if info.Path == rootInfo.Path || isSkipped(skipped, info.Path) {
+			continue
+		}
+
+		err = fn(info.PathDisplay, &info, nil)
+		switch {
+		case errors.Is(err, fs.SkipDir) && info.IsFolder:
+			skipped[info.Path] = true
+		case errors.Is(err, fs.SkipDir) && path.Dir(info.Path) == rootInfo.Path:
+			// Skipping the rest of root ends the walk
+			return nil
+		case errors.Is(err, fs.SkipDir):
+			skipped[path.Dir(info.Path)] = true
+		case err != nil:
+			return walkResult(err)
+		}
+	}
+
+	return nil
+}
+
+// isSkipped reports whether a folder above the entry at lowerPath is skipped
+func isSkipped(skipped map[string]bool, lowerPath string) bool {
+	for dir := path.Dir(lowerPath); ; dir = path.Dir(dir) {
+		if skipped[dir] {
+			return true
+		}
+		if dir == "/" {
+			return false
+		}
+	}
+}
+
+// walkResult turns the skip errors that end a walk into success
+func walkResult(err error) error {
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"iter"
+	"path"
+
+	"valboks/pkg/dropbox"
+)
+
+// Dropbox stores files in the Dropbox account of a client
+type Dropbox struct {
+	client *dropbox.Client
+}
+
+// NewDropbox returns a backend that stores files with client
+func NewDropbox(client *dropbox.Client) *Dropbox {
+	return &Dropbox{client: client}
+}
+
+func dropboxInfo(info *dropbox.FileInfo) FileInfo {
+	modTime := info.ServerModified
+	if !info.ClientModified.IsZero() {
+		modTime = info.ClientModified
+	}
+
+	return FileInfo{
+		Name:        info.Name,
+		Path:        info.PathDisplay,
+		IsDir:       info.IsFolder,
+		Size:        int64(info.Size),
+		ModTime:     modTime,
+		ContentHash: info.ContentHash,
+		Sys:         info,
+	}
+}
+
+func (d *Dropbox) List(ctx context.Context, p string) iter.Seq2[FileInfo, error] {
+	return func(yield func(FileInfo, error) bool) {
+		for info, err := range d.client.ListFolderIter(ctx, p, false) {
+			if err != nil {
+				yield(FileInfo{}, err)
+				return
+			}
+			if !yield(dropboxInfo(&info), nil) {
+				return
+			}
+		}
+	}
+}
+
+// Walk walks the tree at root with a single recursive listing
+func (d *Dropbox) Walk(ctx context.Context, root string, fn WalkFunc) error {
+	return d.client.Walk(ctx, root, func(p string, info *dropbox.FileInfo, err error) error {
+		if info == nil {
+			return fn(p, nil, err)
+		}
+		result := dropboxInfo(info)
+		return fn(p, &result, err)
+	})
+}
+
+func (d *Dropbox) Stat(ctx context.Context, p string) (*FileInfo, error) {
+	if path.Clean("/"+p) == "/" {
+		// Dropbox has no metadata for the root folder
+		return &FileInfo{Path: "/", IsDir: true, Sys: &dropbox.FileInfo{Path: "/", PathDisplay: "/", IsFolder: true}}, nil
+	}
+
+	info, err := d.client.GetFileInfo(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	result := dropboxInfo(info)
+	return &result, nil
+}
+
+func (d *Dropbox) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	_, content, err := d.client.OpenFile(ctx, p)
+	return content, err
+}
+
+func (d *Dropbox) Create(ctx context.Context, p string) (Writer, error) {
+	return d.client.CreateFile(ctx, p, dropbox.UploadOptions{Mode: dropbox.WriteModeOverwrite})
+}
+
+func (d *Dropbox) Mkdir(ctx context.Context, p string) error {
+	return d.client.CreateFolder(ctx, p)
+}
+
+func (d *Dropbox) Delete(ctx context.Context, p string) error {
+	return d.client.DeletePath(ctx, p)
+}
+
+func (d *Dropbox) Move(ctx context.Context, from, to string) error {
+	_, err := d.client.Move(ctx, from, to)
+	return err
+}
+
+func (d *Dropbox) Copy(ctx context.Context, from, to string) error {
+	_, err := d.client.Copy(ctx, from, to)
+	return err
+}
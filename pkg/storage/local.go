@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Local stores files in the local filesystem. Relative paths are resolved
+// against the working directory.
+type Local struct{}
+
+// NewLocal returns the local filesystem backend
+func NewLocal() *Local {
+	return &Local{}
+}
+
+func localInfo(p string, stat fs.FileInfo) FileInfo {
+	info := FileInfo{
+		Name:    stat.Name(),
+		Path:    p,
+		IsDir:   stat.IsDir(),
+		ModTime: stat.ModTime(),
+		Sys:     stat,
+	}
+	if !info.IsDir {
+		info.Size = stat.Size()
+	}
+	return info
+}
+
+// listBatchSize is the number of directory entries read at a time
+const listBatchSize = 256
+
+func (l *Local) List(ctx context.Context, p string) iter.Seq2[FileInfo, error] {
+	return func(yield func(FileInfo, error) bool) {
+		dir, err := os.Open(filepath.FromSlash(p))
+		if err != nil {
+			yield(FileInfo{}, fmt.Errorf("failed to list directory '%s': %w", p, err))
+			return
+		}
+		defer dir.Close()
+
+		for {
+			entries, err := dir.ReadDir(listBatchSize)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(FileInfo{}, fmt.Errorf("failed to list directory '%s': %w", p, err))
+				return
+			}
+
+			for _, entry := range entries {
+				if err := ctx.Err(); err != nil {
+					yield(FileInfo{}, err)
+					return
+				}
+
+				stat, err := entry.Info()
+				if err != nil {
+					yield(FileInfo{}, fmt.Errorf("failed to get the file info: %w", err))
+					return
+				}
+				if !yield(localInfo(path.Join(p, entry.Name()), stat), nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (l *Local) Stat(ctx context.Context, p string) (*FileInfo, error) {
+	stat, err := os.Stat(filepath.FromSlash(p))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the file info: %w", err)
+	}
+
+	info := localInfo(p, stat)
+	return &info, nil
+}
+
+func (l *Local) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.FromSlash(p))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local file '%s': %w", p, err)
+	}
+
+	stat, err := file.Stat()
+	if err == nil && stat.IsDir() {
+		err = fmt.Errorf("'%s' is a directory", p)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// localWriter writes to a temporary file next to the destination that is
+// renamed into place on Close
+type localWriter struct {
+	*os.File
+	path string
+}
+
+func (w *localWriter) Close() error {
+	err := w.File.Close()
+	if err != nil {
+		os.Remove(w.Name())
+		return fmt.Errorf("failed to write local file '%s': %w", w.path, err)
+	}
+
+	err = os.Rename(w.Name(), w.path)
+	if err != nil {
+		os.Remove(w.Name())
+		return fmt.Errorf("failed to move '%s' into place: %w", w.Name(), err)
+	}
+
+	return nil
+}
+
+func (w *localWriter) Abort() error {
+	w.File.Close()
+	return os.Remove(w.Name())
+}
+
+func (l *Local) Create(ctx context.Context, p string) (Writer, error) {
+	localPath := filepath.FromSlash(p)
+
+	err := os.MkdirAll(filepath.Dir(localPath), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create local directory '%s': %w", filepath.Dir(localPath), err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create local file '%s': %w", p, err)
+	}
+
+	return &localWriter{File: file, path: localPath}, nil
+}
+
+func (l *Local) Mkdir(ctx context.Context, p string) error {
+	localPath := filepath.FromSlash(p)
+
+	err := os.MkdirAll(filepath.Dir(localPath), 0755)
+	if err == nil {
+		err = os.Mkdir(localPath, 0755)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create local directory '%s': %w", p, err)
+	}
+
+	return nil
+}
+
+func (l *Local) Delete(ctx context.Context, p string) error {
+	localPath := filepath.FromSlash(p)
+
+	_, err := os.Lstat(localPath)
+	if err == nil {
+		err = os.RemoveAll(localPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete '%s': %w", p, err)
+	}
+
+	return nil
+}
+
+// prepareDestination fails if to exists and creates its parent directories otherwise
+func prepareDestination(to string) error {
+	_, err := os.Lstat(to)
+	if err == nil {
+		return &fs.PathError{Op: "create", Path: to, Err: fs.ErrExist}
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.MkdirAll(filepath.Dir(to), 0755)
+}
+
+func (l *Local) Move(ctx context.Context, from, to string) error {
+	fromPath, toPath := filepath.FromSlash(from), filepath.FromSlash(to)
+
+	err := prepareDestination(toPath)
+	if err == nil {
+		err = os.Rename(fromPath, toPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move '%s' to '%s': %w", from, to, err)
+	}
+
+	return nil
+}
+
+func (l *Local) Copy(ctx context.Context, from, to string) error {
+	fromPath, toPath := filepath.FromSlash(from), filepath.FromSlash(to)
+
+	err := prepareDestination(toPath)
+	if err == nil {
+		err = copyLocal(ctx, fromPath, toPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy '%s' to '%s': %w", from, to, err)
+	}
+
+	return nil
+}
+
+// copyLocal copies the file or directory tree at from to to
+func copyLocal(ctx context.Context, from, to string) error {
+	return filepath.WalkDir(from, func(walkPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(from, walkPath)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+
+		if entry.IsDir() {
+			return os.Mkdir(target, 0755)
+		}
+		if !entry.Type().IsRegular() {
+			// Symlinks and special files have no Dropbox equivalent
+			return nil
+		}
+
+		return copyLocalFile(walkPath, target)
+	})
+}
+
+func copyLocalFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	closeErr := dst.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+// Package storage puts Dropbox and the local filesystem behind a common
+// interface, so that listing, copying and moving files works the same
+// between any two backends.
+//
+// Paths are slash separated. Errors for missing paths match
+// fs.ErrNotExist and errors for existing destinations match fs.ErrExist
+// with errors.Is, whichever backend returned them.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+	"time"
+)
+
+// FileInfo describes a file or folder in a backend
+type FileInfo struct {
+	Name string
+	// Path is the path of the entry in its backend
+	Path    string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+	// ContentHash is the Dropbox content hash, empty if the backend does not store it
+	ContentHash string
+	// Sys is the backend's own description of the entry, a *dropbox.FileInfo
+	// or an fs.FileInfo
+	Sys any
+}
+
+// Writer receives the content of a file being created. The file is only
+// created or replaced when Close succeeds, Abort discards what was written.
+type Writer interface {
+	io.Writer
+	Close() error
+	Abort() error
+}
+
+// Storage is a tree of files and folders
+type Storage interface {
+	// List yields the entries of the folder at path as they are read,
+	// ending after the first error
+	List(ctx context.Context, path string) iter.Seq2[FileInfo, error]
+	// Stat describes the file or folder at path
+	Stat(ctx context.Context, path string) (*FileInfo, error)
+	// Open returns the content of the file at path
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+	// Create returns a writer for a file at path, replacing any file there
+	// and creating missing parent folders
+	Create(ctx context.Context, path string) (Writer, error)
+	// Mkdir creates the folder at path and missing parent folders. It fails
+	// if something already exists at path.
+	Mkdir(ctx context.Context, path string) error
+	// Delete removes the file or folder at path, folders with their contents
+	Delete(ctx context.Context, path string) error
+	// Move moves the file or folder at from to to, which must not exist
+	Move(ctx context.Context, from, to string) error
+	// Copy copies the file or folder at from to to, which must not exist
+	Copy(ctx context.Context, from, to string) error
+}
+
+const (
+	// SchemeDropbox selects Dropbox, as in dropbox:/path
+	SchemeDropbox = "dropbox"
+	// SchemeFile selects the local filesystem, as in file:///path
+	SchemeFile = "file"
+)
+
+// Location is a path in one of the backends
+type Location struct {
+	Scheme string
+	Path   string
+}
+
+// ParseLocation parses a "dropbox:/path" or "file:///path" URI. Arguments
+// without one of these schemes are paths in defaultScheme, so that local
+// file names containing a colon keep working.
+func ParseLocation(arg, defaultScheme string) (Location, error) {
+	if path, ok := strings.CutPrefix(arg, SchemeDropbox+":"); ok {
+		// dropbox://folder/file is accepted as a spelling of dropbox:/folder/file
+		path = strings.TrimPrefix(path, "/")
+		return Location{Scheme: SchemeDropbox, Path: "/" + path}, nil
+	}
+
+	if path, ok := strings.CutPrefix(arg, SchemeFile+":"); ok {
+		if rest, ok := strings.CutPrefix(path, "//"); ok {
+			host, path, _ := strings.Cut(rest, "/")
+			if host != "" && host != "localhost" {
+				return Location{}, fmt.Errorf("unsupported host '%s' in '%s', only local files can be used", host, arg)
+			}
+			return Location{Scheme: SchemeFile, Path: "/" + path}, nil
+		}
+		if path == "" {
+			path = "."
+		}
+		return Location{Scheme: SchemeFile, Path: path}, nil
+	}
+
+	switch defaultScheme {
+	case SchemeDropbox, SchemeFile:
+		return Location{Scheme: defaultScheme, Path: arg}, nil
+	default:
+		return Location{}, fmt.Errorf("unknown storage scheme '%s'", defaultScheme)
+	}
+}
+
+// String returns the location as a URI
+func (l Location) String() string {
+	if l.Scheme == SchemeFile && strings.HasPrefix(l.Path, "/") {
+		return "file://" + l.Path
+	}
+	return l.Scheme + ":" + l.Path
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+)
+
+// Copy copies the file or folder at from in src to to in dst, which must
+// not exist. Within one backend the backend's own Copy is used, between
+// backends the content of every file is streamed from src to dst.
+func Copy(ctx context.Context, src Storage, from string, dst Storage, to string) error {
+	if src == dst {
+		return src.Copy(ctx, from, to)
+	}
+
+	info, err := src.Stat(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	_, err = dst.Stat(ctx, to)
+	if err == nil {
+		return fmt.Errorf("failed to copy '%s' to '%s': %w", from, to, &fs.PathError{Op: "create", Path: to, Err: fs.ErrExist})
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return transfer(ctx, src, info, dst, to)
+}
+
+// Move moves the file or folder at from in src to to in dst, which must not
+// exist. Between backends the source is only deleted once it was copied.
+func Move(ctx context.Context, src Storage, from string, dst Storage, to string) error {
+	if src == dst {
+		return src.Move(ctx, from, to)
+	}
+
+	err := Copy(ctx, src, from, dst, to)
+	if err != nil {
+		return err
+	}
+
+	return src.Delete(ctx, from)
+}
+
+// transfer copies the entry described by info and, for folders, everything
+// below it
+func transfer(ctx context.Context, src Storage, info *FileInfo, dst Storage, to string) error {
+	if !info.IsDir {
+		return transferFile(ctx, src, info.Path, dst, to)
+	}
+
+	err := dst.Mkdir(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	for entry, err := range src.List(ctx, info.Path) {
+		if err == nil {
+			err = transfer(ctx, src, &entry, dst, path.Join(to, entry.Name))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func transferFile(ctx context.Context, src Storage, from string, dst Storage, to string) error {
+	content, err := src.Open(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	w, err := dst.Create(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, content)
+	if err != nil {
+		w.Abort()
+		return fmt.Errorf("failed to copy '%s' to '%s': %w", from, to, err)
+	}
+
+	return w.Close()
+}
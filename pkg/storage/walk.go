@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+)
+
+// WalkFunc is called by Walk for every file and folder, with the rules of
+// fs.WalkDirFunc: returning fs.SkipDir for a folder skips its contents,
+// returning it for a file skips the rest of the file's folder and returning
+// fs.SkipAll ends the walk. When root cannot be looked up or a folder cannot
+// be listed, fn is called with info nil and the error.
+type WalkFunc func(path string, info *FileInfo, err error) error
+
+// Walker is implemented by backends that can walk a tree faster than by
+// listing each folder
+type Walker interface {
+	Walk(ctx context.Context, root string, fn WalkFunc) error
+}
+
+// Walk calls fn for root and everything below it in s, streaming entries
+// as they are listed. The order of entries depends on the backend, but
+// every folder comes before its contents.
+func Walk(ctx context.Context, s Storage, root string, fn WalkFunc) error {
+	if walker, ok := s.(Walker); ok {
+		return walker.Walk(ctx, root, fn)
+	}
+
+	info, err := s.Stat(ctx, root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkFolder(ctx, s, root, info, fn)
+	}
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+func walkFolder(ctx context.Context, s Storage, p string, info *FileInfo, fn WalkFunc) error {
+	err := fn(p, info, nil)
+	if err != nil || !info.IsDir {
+		if errors.Is(err, fs.SkipDir) && info.IsDir {
+			return nil
+		}
+		return err
+	}
+
+	for entry, err := range s.List(ctx, p) {
+		if err != nil {
+			err = fn(p, nil, err)
+			if errors.Is(err, fs.SkipDir) {
+				return nil
+			}
+			return err
+		}
+
+		err = walkFolder(ctx, s, path.Join(p, entry.Name), &entry, fn)
+		if err != nil {
+			// SkipDir from a file skips the rest of this folder
+			if errors.Is(err, fs.SkipDir) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}